@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/willunylabs/wand/router"
+)
+
+type lintConfig struct {
+	ignoreCase  bool
+	strictSlash bool
+	routesJSON  bool
+	failOn      string // "warning", "info" or "none"
+	files       []string
+}
+
+func main() {
+	os.Exit(runCLI(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// runCLI lints route lists ("METHOD [host]/pattern" per line, '#' comments) read from
+// the given files or stdin; with -routes-json the inputs are the JSON encoding of an
+// application's Router.Routes(). Each finding is printed with its severity. It exits 1
+// when routes fail to register or a finding reaches the -fail-on severity.
+func runCLI(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	cfg, err := parseLintConfig(args)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "wandlint: %v\n", err)
+		return 2
+	}
+	r := router.NewRouter()
+	r.IgnoreCase = cfg.ignoreCase
+	r.StrictSlash = cfg.strictSlash
+
+	load := loadRoutes
+	if cfg.routesJSON {
+		load = loadRoutesJSON
+	}
+	failed := false
+	if len(cfg.files) == 0 {
+		failed = load(r, "<stdin>", stdin, stderr)
+	}
+	for _, name := range cfg.files {
+		f, err := os.Open(name) // #nosec G304 -- paths come from the command line
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "wandlint: %v\n", err)
+			return 2
+		}
+		if load(r, name, f, stderr) {
+			failed = true
+		}
+		_ = f.Close()
+	}
+
+	for _, f := range r.Analyze() {
+		sev := f.Kind.Severity()
+		_, _ = fmt.Fprintf(stdout, "%s: %s\n", sev, f)
+		if cfg.failOn == "info" || (cfg.failOn == "warning" && sev >= router.SeverityWarning) {
+			failed = true
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func parseLintConfig(args []string) (lintConfig, error) {
+	cfg := lintConfig{strictSlash: true}
+	fs := flag.NewFlagSet("wandlint", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&cfg.ignoreCase, "ignore-case", cfg.ignoreCase, "match paths case-insensitively (Router.IgnoreCase)")
+	fs.BoolVar(&cfg.strictSlash, "strict-slash", cfg.strictSlash, "redirect on trailing-slash mismatch (Router.StrictSlash)")
+	fs.BoolVar(&cfg.routesJSON, "routes-json", false, "read the JSON encoding of Router.Routes() instead of route lists")
+	fs.StringVar(&cfg.failOn, "fail-on", "warning", "lowest finding severity that exits 1: warning, info or none")
+	if err := fs.Parse(args); err != nil {
+		return lintConfig{}, err
+	}
+	switch cfg.failOn {
+	case "warning", "info", "none":
+	default:
+		return lintConfig{}, fmt.Errorf("invalid -fail-on %q (want warning, info or none)", cfg.failOn)
+	}
+	cfg.files = fs.Args()
+	return cfg, nil
+}
+
+// loadRoutes registers every route in src and reports whether any line failed.
+func loadRoutes(r *router.Router, name string, src io.Reader, stderr io.Writer) bool {
+	failed := false
+	noop := func(http.ResponseWriter, *http.Request) {}
+	sc := bufio.NewScanner(src)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			_, _ = fmt.Fprintf(stderr, "%s:%d: want \"METHOD [host]/pattern\"\n", name, line)
+			failed = true
+			continue
+		}
+		method, pattern := strings.ToUpper(fields[0]), fields[1]
+		if method == "ANY" {
+			method = router.MethodAny
+		}
+		var err error
+		if host, path, ok := splitHost(pattern); ok {
+			err = r.Host(host).Handle(method, path, noop)
+		} else {
+			err = r.Handle(method, pattern, noop)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "%s:%d: %v\n", name, line, err)
+			failed = true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		_, _ = fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		failed = true
+	}
+	return failed
+}
+
+// loadRoutesJSON registers the routes of a JSON-encoded Router.Routes() (e.g. written
+// by the application with json.Marshal(r.Routes())) and reports whether any failed.
+func loadRoutesJSON(r *router.Router, name string, src io.Reader, stderr io.Writer) bool {
+	var routes []router.RouteInfo
+	if err := json.NewDecoder(src).Decode(&routes); err != nil {
+		_, _ = fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		return true
+	}
+	failed := false
+	noop := func(http.ResponseWriter, *http.Request) {}
+	for i, route := range routes {
+		var err error
+		if route.Kind == router.RouteMount {
+			err = r.Host(route.Host).Mount(route.Pattern, http.HandlerFunc(noop))
+		} else {
+			err = r.Host(route.Host).Handle(route.Method, route.Pattern, noop)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "%s: route %d: %v\n", name, i, err)
+			failed = true
+		}
+	}
+	return failed
+}
+
+func splitHost(pattern string) (host, path string, ok bool) {
+	if pattern == "" || pattern[0] == '/' {
+		return "", pattern, false
+	}
+	i := strings.IndexByte(pattern, '/')
+	if i < 0 {
+		return "", pattern, false
+	}
+	return pattern[:i], pattern[i:], true
+}
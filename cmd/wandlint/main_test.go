@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/willunylabs/wand/router"
+)
+
+func TestRunCLI_Findings(t *testing.T) {
+	src := `# api routes
+GET  /users/:id
+GET  /users/new
+ANY  /health
+POST /static/upload
+GET  /static/*path
+GET  api.example.com/v1/:name
+`
+	var out, errOut bytes.Buffer
+	code := runCLI(nil, strings.NewReader(src), &out, &errOut)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1 (stderr %q)", code, errOut.String())
+	}
+	got := out.String()
+	for _, want := range []string{
+		"info: shadowed: GET /users/new shadows GET /users/:id",
+		"warning: wildcard-swallow: GET /static/*path answers GET requests for POST /static/upload",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("output missing %q:\n%s", want, got)
+		}
+	}
+	if errOut.Len() != 0 {
+		t.Fatalf("unexpected stderr: %q", errOut.String())
+	}
+}
+
+func TestRunCLI_Clean(t *testing.T) {
+	var out, errOut bytes.Buffer
+	code := runCLI(nil, strings.NewReader("GET /users/:id\nPOST /users\n"), &out, &errOut)
+	if code != 0 || out.Len() != 0 {
+		t.Fatalf("exit code = %d, output %q", code, out.String())
+	}
+}
+
+func TestRunCLI_RegistrationErrors(t *testing.T) {
+	var out, errOut bytes.Buffer
+	code := runCLI(nil, strings.NewReader("GET /a\nGET /a\nbroken\n"), &out, &errOut)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1", code)
+	}
+	got := errOut.String()
+	if !strings.Contains(got, "<stdin>:2: duplicate route") || !strings.Contains(got, "<stdin>:3:") {
+		t.Fatalf("unexpected stderr: %q", got)
+	}
+}
+
+func TestRunCLI_FilesAndFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.txt")
+	if err := os.WriteFile(path, []byte("GET /About\nGET /about\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var out, errOut bytes.Buffer
+	if code := runCLI([]string{path}, nil, &out, &errOut); code != 1 || !strings.Contains(out.String(), "case-collision") {
+		t.Fatalf("exit code = %d, output %q, stderr %q", code, out.String(), errOut.String())
+	}
+
+	out.Reset()
+	errOut.Reset()
+	if code := runCLI([]string{"-ignore-case", path}, nil, &out, &errOut); code != 1 || !strings.Contains(errOut.String(), "duplicate route") {
+		t.Fatalf("exit code = %d, output %q, stderr %q", code, out.String(), errOut.String())
+	}
+
+	if code := runCLI([]string{"-bogus"}, nil, &out, &errOut); code != 2 {
+		t.Fatalf("exit code = %d, want 2", code)
+	}
+}
+
+func TestRunCLI_FailOn(t *testing.T) {
+	shadow := "GET /users/:id\nGET /users/new\n"
+	var out, errOut bytes.Buffer
+	// Shadowing is informational: reported, but the exit code stays 0.
+	if code := runCLI(nil, strings.NewReader(shadow), &out, &errOut); code != 0 || !strings.Contains(out.String(), "info: shadowed:") {
+		t.Fatalf("exit code = %d, output %q", code, out.String())
+	}
+	if code := runCLI([]string{"-fail-on", "info"}, strings.NewReader(shadow), &out, &errOut); code != 1 {
+		t.Fatalf("-fail-on info: exit code = %d, want 1", code)
+	}
+	swallow := "POST /static/upload\nGET /static/*path\n"
+	if code := runCLI([]string{"-fail-on", "none"}, strings.NewReader(swallow), &out, &errOut); code != 0 {
+		t.Fatalf("-fail-on none: exit code = %d, want 0", code)
+	}
+	if code := runCLI([]string{"-fail-on", "error"}, nil, &out, &errOut); code != 2 {
+		t.Fatalf("invalid -fail-on: exit code = %d, want 2", code)
+	}
+}
+
+func TestRunCLI_RoutesJSON(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request) {}
+	app := router.NewRouter()
+	for _, p := range []string{"/users/:id", "/users/new", "/static/*path"} {
+		if err := app.GET(p, noop); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := app.POST("/static/upload", noop); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Host("api.example.com").GET("/v1/:name", noop); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Mount("/legacy", http.NotFoundHandler()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(app.Routes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+	code := runCLI([]string{"-routes-json"}, bytes.NewReader(data), &out, &errOut)
+	if code != 1 || errOut.Len() != 0 {
+		t.Fatalf("exit code = %d, stderr %q", code, errOut.String())
+	}
+	if want := app.Analyze(); strings.Count(out.String(), "\n") != len(want) || !strings.Contains(out.String(), "wildcard-swallow") {
+		t.Fatalf("expected the %d findings of the application router, got %q", len(want), out.String())
+	}
+
+	if code := runCLI([]string{"-routes-json"}, strings.NewReader("GET /users"), &out, &errOut); code != 1 || !strings.Contains(errOut.String(), "<stdin>:") {
+		t.Fatalf("invalid JSON: exit code = %d, stderr %q", code, errOut.String())
+	}
+}
@@ -15,6 +15,7 @@ type LogEvent struct {
 
 	Method        string
 	Path          string
+	Route         string // matched route pattern, if known
 	Status        uint16
 	Bytes         int64
 	DurationNanos int64
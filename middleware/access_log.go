@@ -12,6 +12,7 @@ import (
 )
 
 // AccessLog writes structured access events into the ring buffer.
+// Like Logger, it records the route pattern only when registered with r.Use or a group.
 func AccessLog(rb *logger.RingBuffer, next http.Handler) http.Handler {
 	if rb == nil {
 		return next
@@ -28,6 +29,7 @@ func AccessLog(rb *logger.RingBuffer, next http.Handler) http.Handler {
 				recovered = rec
 			}
 
+			route := RoutePattern(w)
 			status := sw.status
 			bytes := sw.bytes
 			sw.ResponseWriter = nil
@@ -52,6 +54,7 @@ func AccessLog(rb *logger.RingBuffer, next http.Handler) http.Handler {
 					Timestamp:     end.UnixNano(),
 					Method:        r.Method,
 					Path:          r.URL.Path,
+					Route:         route,
 					Status:        statusToUint16(status),
 					Bytes:         bytes,
 					DurationNanos: end.Sub(start).Nanoseconds(),
@@ -16,6 +16,7 @@ type LogEntry struct {
 	Time       time.Time
 	Method     string
 	Path       string
+	Route      string // matched route pattern (e.g. /users/:id); see Logger
 	Proto      string
 	Status     int
 	Bytes      int64
@@ -29,6 +30,7 @@ type JSONLogEntry struct {
 	Time       string `json:"time"`
 	Method     string `json:"method"`
 	Path       string `json:"path"`
+	Route      string `json:"route,omitempty"`
 	Proto      string `json:"proto"`
 	Status     int    `json:"status"`
 	Bytes      int64  `json:"bytes"`
@@ -51,6 +53,10 @@ type LoggerOptions struct {
 }
 
 // Logger writes a single line per request using the default formatter.
+//
+// LogEntry.Route is only filled when the middleware is registered with r.Use or a group,
+// where the router passes down a writer carrying the matched route. Wrapping the router
+// from outside (Logger(r)) logs an empty route: the match is gone once the router returns.
 func Logger(next http.Handler) http.Handler {
 	return LoggerWith(LoggerOptions{})(next)
 }
@@ -92,6 +98,7 @@ func LoggerWith(opts LoggerOptions) func(http.Handler) http.Handler {
 					recovered = rec
 				}
 
+				route := RoutePattern(w)
 				status := sw.status
 				bytes := sw.bytes
 				sw.ResponseWriter = nil
@@ -117,6 +124,7 @@ func LoggerWith(opts LoggerOptions) func(http.Handler) http.Handler {
 					Time:       end,
 					Method:     r.Method,
 					Path:       r.URL.Path,
+					Route:      route,
 					Proto:      r.Proto,
 					Status:     status,
 					Bytes:      bytes,
@@ -161,6 +169,7 @@ func DefaultLogFormatter(e LogEntry, timeFormat string) string {
 	path := sanitizeLogField(e.Path)
 	proto := sanitizeLogField(e.Proto)
 	requestID := sanitizeLogField(e.RequestID)
+	route := sanitizeLogField(e.Route)
 
 	builder := strings.Builder{}
 	builder.Grow(64)
@@ -183,6 +192,10 @@ func DefaultLogFormatter(e LogEntry, timeFormat string) string {
 		builder.WriteString(" rid=")
 		builder.WriteString(requestID)
 	}
+	if route != "" {
+		builder.WriteString(" route=")
+		builder.WriteString(route)
+	}
 	return builder.String()
 }
 
@@ -193,6 +206,7 @@ func JSONFormatter(e LogEntry) string {
 		Time:       e.Time.Format(time.RFC3339Nano),
 		Method:     e.Method,
 		Path:       e.Path,
+		Route:      e.Route,
 		Proto:      e.Proto,
 		Status:     e.Status,
 		Bytes:      e.Bytes,
@@ -212,6 +226,7 @@ func writeJSONLine(w io.Writer, e LogEntry, timeFormat string) error {
 		Time:       e.Time.Format(timeFormat),
 		Method:     e.Method,
 		Path:       e.Path,
+		Route:      e.Route,
 		Proto:      e.Proto,
 		Status:     e.Status,
 		Bytes:      e.Bytes,
@@ -270,6 +285,7 @@ func sanitizeLogField(s string) string {
 func sanitizeLogEntry(e LogEntry) LogEntry {
 	e.Method = sanitizeLogField(e.Method)
 	e.Path = sanitizeLogField(e.Path)
+	e.Route = sanitizeLogField(e.Route)
 	e.Proto = sanitizeLogField(e.Proto)
 	e.RemoteAddr = sanitizeLogField(e.RemoteAddr)
 	e.RequestID = sanitizeLogField(e.RequestID)
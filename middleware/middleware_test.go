@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/willunylabs/wand/logger"
+	"github.com/willunylabs/wand/router"
 )
 
 func TestRequestID_GeneratesWhenMissing(t *testing.T) {
@@ -623,6 +624,64 @@ func TestLogger_JSON(t *testing.T) {
 	}
 }
 
+type routeRW struct {
+	http.ResponseWriter
+	pattern string
+}
+
+func (w routeRW) RoutePattern() string        { return w.pattern }
+func (w routeRW) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func TestLogger_RoutePattern(t *testing.T) {
+	var buf strings.Builder
+	h := LoggerWith(LoggerOptions{Writer: &buf})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(routeRW{ResponseWriter: rec, pattern: "/users/:id"}, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	if line := buf.String(); !strings.Contains(line, " route=/users/:id") {
+		t.Fatalf("expected route in log line, got %q", line)
+	}
+
+	// Patterns are found through other wrappers.
+	sw := &statusWriter{ResponseWriter: routeRW{ResponseWriter: rec, pattern: "/a/*rest"}}
+	if got := RoutePattern(sw); got != "/a/*rest" {
+		t.Fatalf("expected pattern through Unwrap, got %q", got)
+	}
+	if got := RoutePattern(rec); got != "" {
+		t.Fatalf("expected empty pattern, got %q", got)
+	}
+}
+
+func TestLogger_RouteFromRouter(t *testing.T) {
+	var inside, outside strings.Builder
+	r := router.NewRouter()
+	if err := r.Use(LoggerWith(LoggerOptions{Writer: &inside})); err != nil {
+		t.Fatalf("use: %v", err)
+	}
+	for _, p := range []string{"/health", "/users/:id"} {
+		if err := r.GET(p, func(w http.ResponseWriter, req *http.Request) {}); err != nil {
+			t.Fatalf("register %s: %v", p, err)
+		}
+	}
+	// Wrapping the router from outside: the match is gone when the router returns.
+	h := LoggerWith(LoggerOptions{Writer: &outside})(r)
+
+	for _, tc := range []struct{ path, route string }{
+		{"/health", "/health"},
+		{"/users/42", "/users/:id"},
+	} {
+		inside.Reset()
+		outside.Reset()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if line := inside.String(); !strings.Contains(line, " route="+tc.route) {
+			t.Fatalf("%s: expected route in r.Use log line, got %q", tc.path, line)
+		}
+		if line := outside.String(); line == "" || strings.Contains(line, "route=") {
+			t.Fatalf("%s: expected outside log line without route, got %q", tc.path, line)
+		}
+	}
+}
+
 func TestJSONFormatter(t *testing.T) {
 	line := JSONFormatter(LogEntry{
 		Time:       time.Unix(1700000000, 0),
@@ -0,0 +1,23 @@
+package middleware
+
+import "net/http"
+
+// RoutePattern returns the route pattern that matched the request (e.g. "/users/:id"),
+// or "" if unknown. It walks Unwrap looking for a writer that reports the route, such as
+// the one wand's router passes to middlewares registered with Use or groups.
+// Labeling metrics by pattern instead of URL.Path keeps cardinality bounded.
+func RoutePattern(w http.ResponseWriter) string {
+	for w != nil {
+		if rp, ok := w.(interface{ RoutePattern() string }); ok {
+			if p := rp.RoutePattern(); p != "" {
+				return p
+			}
+		}
+		if uw, ok := w.(interface{ Unwrap() http.ResponseWriter }); ok {
+			w = uw.Unwrap()
+			continue
+		}
+		break
+	}
+	return ""
+}
@@ -0,0 +1,181 @@
+//go:build !race
+
+// The race detector makes sync.Pool drop objects at random, so allocation counts are
+// only meaningful without it.
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// allocShape is a route shape and a request matching it.
+type allocShape struct {
+	method, host, pattern string
+	req                   string // "METHOD path", host taken from the shape
+	mw                    bool   // registered with a route-level middleware
+}
+
+func allocShapes() []allocShape {
+	deep := "/deep" + strings.Repeat("/s", 38) + "/:id"
+	deepPath := "/deep" + strings.Repeat("/s", 38) + "/42"
+	return []allocShape{
+		{http.MethodGet, "", "/", "GET /", false},
+		{http.MethodGet, "", "/users", "GET /users", false},
+		{http.MethodGet, "", "/users/:id", "GET /users/42", false},
+		{http.MethodGet, "", "/orders/:id<int>", "GET /orders/7", false},
+		{http.MethodGet, "", "/posts/:state<draft|published>", "GET /posts/draft", false},
+		{http.MethodGet, "", "/download/:{file}.zip", "GET /download/report.zip", false},
+		{http.MethodGet, "", "/v:{major}/items", "GET /v2/items", false},
+		{http.MethodGet, "", "/articles/:slug?", "GET /articles/hello", false},
+		{http.MethodGet, "", "/static/*path", "GET /static/css/site/main.css", false},
+		{http.MethodGet, "", "/p/:a/:b/:c/:d/:e/:f/:g/:h/:i/:j", "GET /p/1/2/3/4/5/6/7/8/9/10", false},
+		{http.MethodGet, "", deep, "GET " + deepPath, false},
+		{MethodAny, "", "/any/:id", "PURGE /any/1", false},
+		{http.MethodGet, ":tenant.example.com", "/t/:id", "GET /t/9", false},
+		{http.MethodPost, "", "/users/:id/posts", "POST /users/1/posts", false},
+		{http.MethodGet, "", "/users/:id/posts", "HEAD /users/1/posts", false}, // HEAD falls back to GET
+		{http.MethodPut, "", "/upload/:id", "PUT /upload/3", true},
+	}
+}
+
+// TestRouter_ZeroAllocShapes serves every registered route shape and fails when one
+// allocates, on Router and both FrozenRouter matchers. Add a shape here for every new
+// kind of route.
+func TestRouter_ZeroAllocShapes(t *testing.T) {
+	r := NewRouter()
+	handler := func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = Param(w, "id")
+	}
+	mw := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_, _ = MatchedRoute(w)
+			next.ServeHTTP(w, req)
+		})
+	}
+	shapes := allocShapes()
+	for _, s := range shapes {
+		var err error
+		if s.mw {
+			err = r.HandleWith(s.method, s.pattern, handler, RouteOptions{Middlewares: []Middleware{mw}})
+		} else if s.host != "" {
+			err = r.Host(s.host).Handle(s.method, s.pattern, handler)
+		} else {
+			err = r.Handle(s.method, s.pattern, handler)
+		}
+		if err != nil {
+			t.Fatalf("register %s %s: %v", s.method, s.pattern, err)
+		}
+	}
+	tree, err := r.Freeze()
+	if err != nil {
+		t.Fatalf("freeze failed: %v", err)
+	}
+	flat, err := r.FreezeWith(FreezeOptions{Matcher: MatcherFlat})
+	if err != nil {
+		t.Fatalf("freeze flat failed: %v", err)
+	}
+
+	// Every registered route must be exercised by a shape.
+	covered := make(map[string]bool)
+	for _, s := range shapes {
+		covered[s.method+" "+s.host+s.pattern] = true
+	}
+	for _, info := range r.Routes() {
+		if !covered[info.Method+" "+info.Host+info.Pattern] {
+			t.Fatalf("route %s %s%s has no allocation shape", info.Method, info.Host, info.Pattern)
+		}
+	}
+
+	for name, h := range map[string]http.Handler{"router": r, "tree": tree, "flat": flat} {
+		for _, s := range shapes {
+			method, path, _ := strings.Cut(s.req, " ")
+			req := httptest.NewRequest(method, path, nil)
+			if s.host != "" {
+				req.Host = strings.Replace(s.host, ":tenant", "acme", 1)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("%s: %s got %d, want 200", name, s.req, rec.Code)
+			}
+			w := &nopRW{header: make(http.Header)}
+			if allocs := testing.AllocsPerRun(100, func() { h.ServeHTTP(w, req) }); allocs != 0 {
+				t.Errorf("%s: %s %s allocates %.1f times per request", name, s.method, s.pattern, allocs)
+			}
+		}
+	}
+}
+
+// TestRouter_PoolSizing checks that objects allocated by the pools, as after a GC,
+// already fit the largest registered route.
+func TestRouter_PoolSizing(t *testing.T) {
+	r := NewRouter()
+	if p := r.paramPool.New().(*Params); cap(p.Keys) != defaultParamsCap {
+		t.Fatalf("expected default params capacity %d, got %d", defaultParamsCap, cap(p.Keys))
+	}
+	var pattern strings.Builder
+	for i := 0; i < 30; i++ {
+		fmt.Fprintf(&pattern, "/:p%d", i)
+	}
+	mustGET(t, r, "/x"+pattern.String(), func(http.ResponseWriter, *http.Request) {})
+	mustGET(t, r, "/y", func(http.ResponseWriter, *http.Request) {})
+
+	flat, err := r.FreezeWith(FreezeOptions{Matcher: MatcherFlat})
+	if err != nil {
+		t.Fatalf("freeze failed: %v", err)
+	}
+	tree, err := r.Freeze()
+	if err != nil {
+		t.Fatalf("freeze failed: %v", err)
+	}
+	for name, pools := range map[string][2]interface{}{
+		"router": {r.paramPool.New(), r.partsPool.New()},
+		"tree":   {tree.paramPool.New(), tree.partsPool.New()},
+		"flat":   {flat.paramPool.New(), flat.partsPool.New()},
+	} {
+		params, segs := pools[0].(*Params), pools[1].(*pathSegments)
+		if cap(params.Keys) < 30 || cap(params.Values) < 30 {
+			t.Fatalf("%s: params capacity %d, want 30", name, cap(params.Keys))
+		}
+		if cap(segs.parts) < 31 || cap(segs.indices) < 32 {
+			t.Fatalf("%s: segments capacity %d/%d, want 31/32", name, cap(segs.parts), cap(segs.indices))
+		}
+		if wantFrames := name == "flat"; (cap(segs.frames) >= flatMaxFrames) != wantFrames {
+			t.Fatalf("%s: flat frames capacity %d, want preallocated = %v", name, cap(segs.frames), wantFrames)
+		}
+		if wantFrames := name == "tree"; (cap(segs.treeFrames) >= treeMaxFrames) != wantFrames {
+			t.Fatalf("%s: tree frames capacity %d, want preallocated = %v", name, cap(segs.treeFrames), wantFrames)
+		}
+	}
+}
+
+// TestRouter_CanonicalCaseAllocs checks that CanonicalCase adds no allocation to a
+// request already in the registered casing.
+func TestRouter_CanonicalCaseAllocs(t *testing.T) {
+	build := func(policy NormalizePolicy) []http.Handler {
+		r := NewRouter()
+		r.IgnoreCase = true
+		r.Normalize = policy
+		mustGET(t, r, "/Users/:id", func(w http.ResponseWriter, _ *http.Request) {})
+		mustGET(t, r, "/API/v1/*path", func(w http.ResponseWriter, _ *http.Request) {})
+		mustGET(t, r, "/Docs/:page?", func(w http.ResponseWriter, _ *http.Request) {})
+		return []http.Handler{r, mustFreeze(t, r)}
+	}
+	plain := build(NormalizePolicy{})
+	canonical := build(NormalizePolicy{Case: NormalizeRedirect, CanonicalCase: true})
+	for _, path := range []string{"/Users/AbC", "/API/v1/x/Y", "/Docs", "/Docs/Intro/"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := &nopRW{header: make(http.Header)}
+		for i := range plain {
+			want := testing.AllocsPerRun(100, func() { plain[i].ServeHTTP(w, req) })
+			if got := testing.AllocsPerRun(100, func() { canonical[i].ServeHTTP(w, req) }); got != want {
+				t.Errorf("%s (handler %d): %.1f allocs with CanonicalCase, %.1f without", path, i, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,537 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// FindingKind classifies an issue reported by Analyze.
+type FindingKind uint8
+
+const (
+	// FindingUnreachable: every request the route matches is taken by another route.
+	FindingUnreachable FindingKind = iota
+	// FindingShadowed: some requests the route matches go to a more specific route
+	// (e.g. /users/:id never sees "new" when /users/new exists).
+	FindingShadowed
+	// FindingWildcardSwallow: a wildcard route takes requests meant for another route,
+	// or answers a method that only exists elsewhere (so clients get 200 instead of 405).
+	FindingWildcardSwallow
+	// FindingCaseCollision: routes differ only by case, or a constraint is still
+	// case-sensitive under IgnoreCase.
+	FindingCaseCollision
+	// FindingTrailingSlash: /path and /path/ are both registered, which changes
+	// what StrictSlash does for them.
+	FindingTrailingSlash
+)
+
+func (k FindingKind) String() string {
+	switch k {
+	case FindingUnreachable:
+		return "unreachable"
+	case FindingShadowed:
+		return "shadowed"
+	case FindingWildcardSwallow:
+		return "wildcard-swallow"
+	case FindingCaseCollision:
+		return "case-collision"
+	case FindingTrailingSlash:
+		return "trailing-slash"
+	default:
+		return "unknown"
+	}
+}
+
+// Severity ranks findings: warnings are likely mistakes, info findings are usually
+// intended (a static route carved out of a param route, such as /users/new next to
+// /users/:id).
+type Severity uint8
+
+const (
+	// SeverityInfo: worth knowing, usually intended.
+	SeverityInfo Severity = iota
+	// SeverityWarning: likely a mistake.
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "unknown"
+	}
+}
+
+// Severity returns the severity of findings of kind k: FindingShadowed is informational,
+// every other kind is a warning.
+func (k FindingKind) Severity() Severity {
+	if k == FindingShadowed {
+		return SeverityInfo
+	}
+	return SeverityWarning
+}
+
+// Finding is a precedence issue reported by Analyze.
+type Finding struct {
+	Kind    FindingKind
+	Route   RouteInfo // the affected route
+	Other   RouteInfo // the route it interacts with
+	Message string
+}
+
+func (f Finding) String() string {
+	return f.Kind.String() + ": " + f.Message
+}
+
+// Analyze reports routes whose requests are (partly) served by other routes because of
+// the Static > Mixed > Constrained > Param > Wildcard precedence, method fallbacks,
+// IgnoreCase and StrictSlash. Registration already rejects hard conflicts; these
+// findings are legal configurations that are easy to get wrong.
+//
+// Overlap between two regular-expression constraints is checked with sample values,
+// so it is a heuristic; everything else is exact.
+func (r *Router) Analyze() []Finding {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ignoreCase := r.IgnoreCase
+	if r.ignoreCaseSet {
+		ignoreCase = r.ignoreCaseEnabled
+	}
+	a := analyzer{strictSlash: r.StrictSlash, ignoreCase: ignoreCase, seen: make(map[string]struct{})}
+	a.table(lintRoutes("", &r.table))
+	hosts := make([]string, 0, len(r.hosts))
+	for host := range r.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		a.table(lintRoutes(host, r.hosts[host]))
+	}
+
+	sort.SliceStable(a.findings, func(i, j int) bool {
+		fi, fj := a.findings[i], a.findings[j]
+		if fi.Kind != fj.Kind {
+			return fi.Kind < fj.Kind
+		}
+		if fi.Route.Host != fj.Route.Host {
+			return fi.Route.Host < fj.Route.Host
+		}
+		return fi.Route.Pattern < fj.Route.Pattern
+	})
+	return a.findings
+}
+
+// lintRoute is one registered variant with the trie nodes on its path.
+type lintRoute struct {
+	method   string
+	info     *RouteInfo
+	pattern  string // match form (lowercased literals with IgnoreCase)
+	segs     []lintSeg
+	trailing bool
+}
+
+// lintSeg is a node on a route's path with its precedence among its siblings.
+type lintSeg struct {
+	n    *node
+	seg  segment
+	rank int // lower is tried first
+}
+
+func (lr *lintRoute) wildcard() bool {
+	return len(lr.segs) > 0 && lr.segs[len(lr.segs)-1].seg.kind == segmentWildcard
+}
+
+func (lr *lintRoute) String() string {
+	s := lr.method + " " + lr.info.Host + lr.info.Pattern
+	if lr.pattern != lr.info.Pattern && strings.IndexByte(lr.info.Pattern, '?') >= 0 {
+		s += " (as " + lr.pattern + ")"
+	}
+	return s
+}
+
+func lintRoutes(host string, table *routeTable) []*lintRoute {
+	methods := make([]string, 0, len(table.roots))
+	for method := range table.roots {
+		methods = append(methods, method)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methodLess(methods[i], methods[j]) })
+
+	var out []*lintRoute
+	for _, method := range methods {
+		var stack []lintSeg
+		var walk func(n *node)
+		walk = func(n *node) {
+			if n.pattern != "" && n.route != nil {
+				out = append(out, &lintRoute{
+					method:   method,
+					info:     n.route,
+					pattern:  n.pattern,
+					segs:     append([]lintSeg(nil), stack...),
+					trailing: len(n.pattern) > 1 && n.pattern[len(n.pattern)-1] == '/',
+				})
+			}
+			rank := 0
+			visit := func(child *node) {
+				seg, _ := parseSegment(child.part)
+				stack = append(stack, lintSeg{n: child, seg: seg, rank: rank})
+				walk(child)
+				stack = stack[:len(stack)-1]
+			}
+			var statics []*node
+			n.staticChildren.rangeFn(func(_ string, child *node) bool {
+				statics = append(statics, child)
+				return true
+			})
+			sort.Slice(statics, func(i, j int) bool { return statics[i].part < statics[j].part })
+			for _, child := range statics {
+				visit(child)
+			}
+			for _, group := range [][]*node{n.mixed, n.constrained, {n.paramChild}, {n.wildChild}} {
+				for _, child := range group {
+					rank++
+					if child != nil {
+						visit(child)
+					}
+				}
+			}
+		}
+		walk(table.roots[method])
+	}
+	return out
+}
+
+type analyzer struct {
+	strictSlash bool
+	ignoreCase  bool
+	findings    []Finding
+	seen        map[string]struct{}
+}
+
+func (a *analyzer) report(kind FindingKind, route, other *RouteInfo, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	key := kind.String() + "\x00" + msg
+	if _, dup := a.seen[key]; dup {
+		return
+	}
+	a.seen[key] = struct{}{}
+	f := Finding{Kind: kind, Route: *route, Message: msg}
+	if other != nil {
+		f.Other = *other
+	}
+	a.findings = append(a.findings, f)
+}
+
+func (a *analyzer) table(routes []*lintRoute) {
+	byPattern := make(map[string]bool, len(routes)) // method + pattern
+	for _, lr := range routes {
+		byPattern[lr.method+" "+lr.pattern] = true
+	}
+	for i, x := range routes {
+		for _, y := range routes[i+1:] {
+			if x.info == y.info {
+				continue
+			}
+			switch {
+			case x.method == y.method:
+				a.samePrecedence(x, y)
+			case x.method == MethodAny || y.method == MethodAny:
+				a.anyPrecedence(x, y)
+			default:
+				a.crossMethod(x, y, byPattern)
+				a.crossMethod(y, x, byPattern)
+			}
+		}
+	}
+	a.caseCollisions(routes)
+	a.trailingSlashPairs(routes)
+}
+
+// samePrecedence compares two routes of one method tree: the one whose branch is
+// tried first at their first differing node wins every request both match.
+func (a *analyzer) samePrecedence(x, y *lintRoute) {
+	d := 0
+	for d < len(x.segs) && d < len(y.segs) && x.segs[d].n == y.segs[d].n {
+		d++
+	}
+	if !a.overlap(x, y, d) {
+		return
+	}
+	win, lose := x, y
+	switch {
+	case d == len(y.segs):
+		win, lose = y, x // y ends at a shared node: the leaf wins over a deeper wildcard
+	case d < len(x.segs) && y.segs[d].rank < x.segs[d].rank:
+		win, lose = y, x
+	}
+	switch {
+	case a.covers(win, lose, d):
+		a.report(FindingUnreachable, lose.info, win.info, "%s is unreachable: every request it matches goes to %s", lose, win)
+	case win.wildcard():
+		a.report(FindingWildcardSwallow, lose.info, win.info, "%s takes some requests matching %s", win, lose)
+	default:
+		a.report(FindingShadowed, lose.info, win.info, "%s shadows %s for requests matching both", win, lose)
+	}
+}
+
+// anyPrecedence compares an explicit-method route with a MethodAny route:
+// the explicit method is tried first regardless of pattern specificity.
+func (a *analyzer) anyPrecedence(x, y *lintRoute) {
+	explicit, anyRoute := x, y
+	if x.method == MethodAny {
+		explicit, anyRoute = y, x
+	}
+	if !a.overlap(explicit, anyRoute, 0) {
+		return
+	}
+	if a.covers(explicit, anyRoute, 0) && explicit.pattern == anyRoute.pattern {
+		return // the usual "Any plus explicit overrides" setup
+	}
+	a.report(FindingShadowed, anyRoute.info, explicit.info, "%s takes %s requests matching %s, even where the Any route is more specific", explicit, explicit.method, anyRoute)
+}
+
+// crossMethod reports a wildcard route answering a path whose specific route only
+// exists for other methods, turning the expected 405 into a wildcard response.
+func (a *analyzer) crossMethod(w, other *lintRoute, byPattern map[string]bool) {
+	if !w.wildcard() || other.wildcard() {
+		return
+	}
+	if byPattern[w.method+" "+other.pattern] || (w.method == http.MethodGet && byPattern[MethodAny+" "+other.pattern]) {
+		return
+	}
+	if !a.overlap(w, other, 0) {
+		return
+	}
+	a.report(FindingWildcardSwallow, other.info, w.info, "%s answers %s requests for %s instead of 405", w, w.method, other)
+}
+
+func (a *analyzer) caseCollisions(routes []*lintRoute) {
+	if a.ignoreCase {
+		for _, lr := range routes {
+			for _, s := range lr.segs {
+				for _, tok := range s.seg.tokens {
+					if tok.expr == "" || tok.name == "" {
+						continue
+					}
+					if values, ok := enumValues(tok.expr); ok && hasLetter(values) {
+						a.report(FindingCaseCollision, lr.info, nil, "%s: constraint <%s> is case-sensitive although IgnoreCase is on", lr, tok.expr)
+					}
+				}
+			}
+		}
+		return
+	}
+	folded := make(map[string]*lintRoute, len(routes))
+	for _, lr := range routes {
+		key := lr.method + " " + lowerPatternLiterals(lr.pattern)
+		if prev, ok := folded[key]; ok && prev.info != lr.info {
+			a.report(FindingCaseCollision, lr.info, prev.info, "%s and %s differ only by case and collide if IgnoreCase is enabled", prev, lr)
+			continue
+		}
+		folded[key] = lr
+	}
+}
+
+// trailingSlashPairs reports /path and /path/ registered for different methods (the same
+// method is a duplicate, since segments ignore trailing slashes).
+func (a *analyzer) trailingSlashPairs(routes []*lintRoute) {
+	bare := make(map[string][]*lintRoute)
+	for _, lr := range routes {
+		if !lr.trailing && !lr.wildcard() {
+			bare[lr.pattern] = append(bare[lr.pattern], lr)
+		}
+	}
+	for _, slashed := range routes {
+		if !slashed.trailing {
+			continue
+		}
+		for _, plain := range bare[strings.TrimSuffix(slashed.pattern, "/")] {
+			if a.strictSlash {
+				a.report(FindingTrailingSlash, slashed.info, plain.info, "%s and %s: StrictSlash redirects %s %s and %s %s to the other form", plain, slashed, plain.method, slashed.pattern, slashed.method, plain.pattern)
+			} else {
+				a.report(FindingTrailingSlash, slashed.info, plain.info, "%s and %s: without StrictSlash both serve either form, so the slash does not separate them", plain, slashed)
+			}
+		}
+	}
+}
+
+// overlap reports whether some path matches both routes, comparing from segment d.
+func (a *analyzer) overlap(x, y *lintRoute, d int) bool {
+	for i := d; ; i++ {
+		if (i < len(x.segs) && x.segs[i].seg.kind == segmentWildcard) || (i < len(y.segs) && y.segs[i].seg.kind == segmentWildcard) {
+			return true // a wildcard takes the rest, including nothing
+		}
+		if i == len(x.segs) || i == len(y.segs) {
+			return len(x.segs) == len(y.segs) && (!a.strictSlash || x.trailing == y.trailing)
+		}
+		if !segOverlap(x.segs[i], y.segs[i]) {
+			return false
+		}
+	}
+}
+
+// covers reports whether every path matched by lose (from segment d) is matched by win.
+func (a *analyzer) covers(win, lose *lintRoute, d int) bool {
+	for i := d; ; i++ {
+		if i < len(win.segs) && win.segs[i].seg.kind == segmentWildcard {
+			return true
+		}
+		if i == len(win.segs) || i == len(lose.segs) {
+			return len(win.segs) == len(lose.segs) && (!a.strictSlash || win.trailing == lose.trailing)
+		}
+		if lose.segs[i].seg.kind == segmentWildcard || !segCovers(win.segs[i], lose.segs[i]) {
+			return false
+		}
+	}
+}
+
+func segOverlap(x, y lintSeg) bool {
+	if x.n.part == y.n.part {
+		return true
+	}
+	if x.seg.kind == segmentStatic {
+		return segAccepts(y, x.n.part)
+	}
+	if y.seg.kind == segmentStatic {
+		return segAccepts(x, y.n.part)
+	}
+	if isPlainParam(x) || isPlainParam(y) {
+		return true
+	}
+	for _, probe := range append(segProbes(x), segProbes(y)...) {
+		if segAccepts(x, probe) && segAccepts(y, probe) {
+			return true
+		}
+	}
+	return false
+}
+
+func segCovers(win, lose lintSeg) bool {
+	if win.n.part == lose.n.part {
+		return true
+	}
+	if lose.seg.kind == segmentStatic {
+		return segAccepts(win, lose.n.part)
+	}
+	if win.seg.kind != segmentParam {
+		return false
+	}
+	if win.n.constraint == nil || constraintUniversal(win.n.constraint.expr) {
+		return true
+	}
+	if lose.seg.kind != segmentParam || lose.n.constraint == nil {
+		return false
+	}
+	we, le := win.n.constraint.expr, lose.n.constraint.expr
+	if we == le || (we == "int" && le == "uint") {
+		return true
+	}
+	if values, ok := enumValues(le); ok {
+		for _, v := range values {
+			if !win.n.constraint.match(v) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func isPlainParam(s lintSeg) bool {
+	return s.seg.kind == segmentWildcard || (s.seg.kind == segmentParam && s.n.constraint == nil)
+}
+
+func segAccepts(s lintSeg, v string) bool {
+	switch s.seg.kind {
+	case segmentStatic:
+		return s.n.part == v
+	case segmentParam:
+		return v != "" && (s.n.constraint == nil || s.n.constraint.match(v))
+	case segmentMixed:
+		return s.n.template.match(v, v, nil)
+	default:
+		return true
+	}
+}
+
+// lintProbes are sample segment values used to compare constraints.
+var lintProbes = []string{
+	"0", "1", "42", "-1", "a", "z", "abc", "Z", "ABC", "a1", "x-y", "a_b", "a.b", "v1",
+	"550e8400-e29b-41d4-a716-446655440000",
+}
+
+func segProbes(s lintSeg) []string {
+	probes := lintProbes
+	if s.n.constraint != nil {
+		if values, ok := enumValues(s.n.constraint.expr); ok {
+			probes = append(append([]string(nil), values...), probes...)
+		}
+	}
+	if s.seg.kind != segmentMixed {
+		return probes
+	}
+	// Fill the template's params with each probe.
+	out := make([]string, 0, len(probes))
+	for _, p := range probes {
+		var b strings.Builder
+		for _, tok := range s.seg.tokens {
+			if tok.name == "" {
+				b.WriteString(tok.literal)
+			} else {
+				b.WriteString(p)
+			}
+		}
+		out = append(out, b.String())
+	}
+	return out
+}
+
+// constraintUniversal reports whether expr accepts every non-empty segment (e.g. ".+").
+func constraintUniversal(expr string) bool {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return false
+	}
+	re = re.Simplify()
+	if re.Op != syntax.OpPlus && re.Op != syntax.OpStar {
+		return false
+	}
+	sub := re.Sub[0]
+	switch sub.Op {
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return true
+	case syntax.OpCharClass:
+		// Everything except possibly '/' and '\n', which never occur in a segment.
+		var covered int
+		for i := 0; i+1 < len(sub.Rune); i += 2 {
+			covered += int(sub.Rune[i+1]-sub.Rune[i]) + 1
+		}
+		return covered >= 0x10FFFF+1-2
+	}
+	return false
+}
+
+func lowerPatternLiterals(pattern string) string {
+	parts := strings.Split(pattern, "/")
+	for i, part := range parts {
+		parts[i] = lowerSegmentLiterals(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+func hasLetter(values []string) bool {
+	for _, v := range values {
+		for i := 0; i < len(v); i++ {
+			if c := v[i]; (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+				return true
+			}
+		}
+	}
+	return false
+}
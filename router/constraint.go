@@ -0,0 +1,86 @@
+package router
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// paramConstraint validates a param value during search.
+// Syntax: ":name<expr>" where expr is a builtin (int, uint, uuid) or a regular expression.
+// A regular expression made only of literal alternatives (e.g. "draft|published") is
+// matched by string comparison instead of the regexp engine.
+type paramConstraint struct {
+	expr  string // as written between '<' and '>'
+	match func(string) bool
+}
+
+func compileConstraint(expr string) (*paramConstraint, error) {
+	switch expr {
+	case "int":
+		return &paramConstraint{expr: expr, match: isIntValue}, nil
+	case "uint":
+		return &paramConstraint{expr: expr, match: isDigits}, nil
+	case "uuid":
+		return &paramConstraint{expr: expr, match: isUUIDValue}, nil
+	}
+	if values, ok := enumValues(expr); ok {
+		return &paramConstraint{expr: expr, match: func(s string) bool {
+			for _, v := range values {
+				if s == v {
+					return true
+				}
+			}
+			return false
+		}}, nil
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid constraint <%s>: %v", expr, err)
+	}
+	return &paramConstraint{expr: expr, match: re.MatchString}, nil
+}
+
+// enumValues reports whether expr is a plain alternation of literal words.
+func enumValues(expr string) ([]string, bool) {
+	values := strings.Split(expr, "|")
+	for _, v := range values {
+		if v == "" {
+			return nil, false
+		}
+		for i := 0; i < len(v); i++ {
+			c := v[i]
+			if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '_' && c != '-' {
+				return nil, false
+			}
+		}
+	}
+	return values, true
+}
+
+func isIntValue(s string) bool {
+	if len(s) > 0 && s[0] == '-' {
+		s = s[1:]
+	}
+	return isDigits(s)
+}
+
+func isUUIDValue(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,460 @@
+package router
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// FrozenMatcher selects how a FrozenRouter matches dynamic paths.
+type FrozenMatcher uint8
+
+const (
+	// MatcherTree searches the compressed frozen trie recursively (default).
+	MatcherTree FrozenMatcher = iota
+	// MatcherFlat compiles each method tree into a flat array-based automaton:
+	// states and edges live in contiguous slices, static children are found with a
+	// per-state perfect hash, and backtracking uses a fixed-size explicit stack instead
+	// of recursion. It favors large route sets (thousands of routes) where pointer
+	// chasing dominates; matching semantics are identical to MatcherTree.
+	MatcherFlat
+)
+
+func (m FrozenMatcher) String() string {
+	switch m {
+	case MatcherTree:
+		return "tree"
+	case MatcherFlat:
+		return "flat"
+	default:
+		return "unknown"
+	}
+}
+
+func parseFrozenMatcher(name string) (FrozenMatcher, bool) {
+	for _, m := range []FrozenMatcher{MatcherTree, MatcherFlat} {
+		if m.String() == name {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+// FreezeOptions configures FreezeWith.
+type FreezeOptions struct {
+	Matcher FrozenMatcher
+}
+
+// FreezeWith is Freeze with options, e.g. FreezeOptions{Matcher: MatcherFlat}.
+func (r *Router) FreezeWith(opts FreezeOptions) (*FrozenRouter, error) {
+	if opts.Matcher != MatcherTree && opts.Matcher != MatcherFlat {
+		return nil, fmt.Errorf("unknown frozen matcher: %d", opts.Matcher)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.freezeLocked(opts.Matcher), nil
+}
+
+// frozenMatcher finds the leaf matching a path in one method tree. With params it also
+// captures param values; callers search without params first and only capture on a hit.
+type frozenMatcher interface {
+	match(segs *pathSegments, params *Params) *frozenNode
+}
+
+func (n *frozenNode) match(segs *pathSegments, params *Params) *frozenNode {
+	return n.search(segs, params)
+}
+
+func newFrozenMatcher(root *frozenNode, kind FrozenMatcher) frozenMatcher {
+	if kind == MatcherFlat {
+		return compileFlat(root)
+	}
+	return root
+}
+
+// flatMatcher is a frozen method tree compiled into flat arrays. State 0 is the root.
+// Static spans and edge labels are packed into one string, so the bytes compared on a
+// path sit next to each other in memory.
+type flatMatcher struct {
+	states   []flatState
+	children []int32    // mixed and constrained child lists, referenced by range
+	edges    []flatEdge // static children, referenced by range
+	slots    []int32    // perfect-hash tables over edges (edge index+1, 0 = empty)
+	text     string     // packed spans and edge labels
+
+	arena []byte // text under construction
+}
+
+// flatState mirrors a frozenNode. Child references are state indices (-1 for none).
+// Fields used on every step come first.
+type flatState struct {
+	spanOff, spanLen   uint32 // static chain matched at the current height ("a/b/c"), in text
+	spanSegs           int32
+	edgeStart, edgeEnd int32  // static children in edges
+	slotStart          int32  // perfect-hash table in slots
+	seed               uint32 // hash multiplier seed
+	slotBits           uint8  // log2 of the table size; 0 scans edges linearly
+	fullHash           bool   // hash whole segments (flatKey collides among the edges)
+	wildcard           bool
+	alternatives       bool // has mixed, constrained, param or wildcard children
+	leaf               *frozenNode
+
+	mixedStart, mixedEnd int32
+	consEnd              int32 // constrained children follow the mixed ones
+	param, wild          int32
+	name                 string
+	constraint           *paramConstraint
+	template             *segmentTemplate
+}
+
+type flatEdge struct {
+	off, len uint32 // label in text
+	state    int32
+}
+
+// flatLinearEdges is the largest static fan-out scanned linearly instead of hashed.
+const flatLinearEdges = 4
+
+func compileFlat(root *frozenNode) *flatMatcher {
+	m := &flatMatcher{}
+	m.compile(root)
+	m.text = string(m.arena)
+	m.arena = nil
+	return m
+}
+
+func (m *flatMatcher) pack(s string) (uint32, uint32) {
+	off := uint32(len(m.arena))
+	m.arena = append(m.arena, s...)
+	return off, uint32(len(s))
+}
+
+// compile appends n and its subtree in preorder and returns n's state index.
+func (m *flatMatcher) compile(n *frozenNode) int32 {
+	id := int32(len(m.states))
+	spanOff, spanLen := m.pack(n.staticSpan)
+	m.states = append(m.states, flatState{
+		spanOff:    spanOff,
+		spanLen:    spanLen,
+		spanSegs:   int32(n.spanSegs),
+		wildcard:   len(n.part) > 0 && n.part[0] == '*',
+		name:       n.name,
+		constraint: n.constraint,
+		template:   n.template,
+		param:      -1,
+		wild:       -1,
+	})
+	if n.pattern != "" {
+		m.states[id].leaf = n
+	}
+
+	var labels []string
+	var edges []flatEdge
+	if n.staticChildren != nil {
+		add := func(part string, child *frozenNode) {
+			labels = append(labels, part)
+			edges = append(edges, flatEdge{state: m.compile(child)})
+		}
+		if n.staticChildren.m != nil {
+			for part, child := range n.staticChildren.m {
+				add(part, child)
+			}
+		} else {
+			for _, c := range n.staticChildren.small {
+				add(c.part, c.node)
+			}
+		}
+	}
+	mixed := m.compileAll(n.mixed)
+	constrained := m.compileAll(n.constrained)
+	param, wild := int32(-1), int32(-1)
+	if n.paramChild != nil {
+		param = m.compile(n.paramChild)
+	}
+	if n.wildChild != nil {
+		wild = m.compile(n.wildChild)
+	}
+
+	s := &m.states[id]
+	s.param, s.wild = param, wild
+	s.edgeStart = int32(len(m.edges))
+	for i := range edges {
+		edges[i].off, edges[i].len = m.pack(labels[i])
+	}
+	m.edges = append(m.edges, edges...)
+	s.edgeEnd = int32(len(m.edges))
+	if len(edges) > flatLinearEdges {
+		table, seed, full := perfectHash(labels, s.edgeStart)
+		s.slotStart = int32(len(m.slots))
+		s.slotBits = uint8(bits.TrailingZeros(uint(len(table))))
+		s.seed = seed
+		s.fullHash = full
+		m.slots = append(m.slots, table...)
+	}
+	s.mixedStart = int32(len(m.children))
+	m.children = append(m.children, mixed...)
+	s.mixedEnd = int32(len(m.children))
+	m.children = append(m.children, constrained...)
+	s.consEnd = int32(len(m.children))
+	s.alternatives = s.consEnd > s.mixedStart || param >= 0 || wild >= 0
+	return id
+}
+
+func (m *flatMatcher) compileAll(nodes []*frozenNode) []int32 {
+	ids := make([]int32, len(nodes))
+	for i, n := range nodes {
+		ids[i] = m.compile(n)
+	}
+	return ids
+}
+
+// perfectHash finds a seed and a power-of-two table where every label gets its own slot.
+// It hashes flatKey when the keys are distinct among the labels, whole labels otherwise.
+func perfectHash(labels []string, base int32) (table []int32, seed uint32, full bool) {
+	seen := make(map[uint64]bool, len(labels))
+	for _, label := range labels {
+		key := flatKey(label)
+		if seen[key] {
+			full = true
+			break
+		}
+		seen[key] = true
+	}
+	bits := uint32(1)
+	for 1<<bits < 2*len(labels) {
+		bits++
+	}
+	for ; ; bits++ {
+		table = make([]int32, 1<<bits)
+	seeds:
+		for seed = 1; seed < 1<<12; seed++ {
+			clear(table)
+			for i, label := range labels {
+				slot := flatSlot(label, seed, bits, full)
+				if table[slot] != 0 {
+					continue seeds
+				}
+				table[slot] = base + int32(i) + 1
+			}
+			return table, seed, full
+		}
+	}
+}
+
+// flatKey packs a non-empty segment into 64 bits from word loads. It reads every byte
+// of segments up to 16 bytes long; collisions are detected when tables are built.
+func flatKey(s string) uint64 {
+	n := len(s)
+	switch {
+	case n >= 8:
+		return load64(s) ^ bits.RotateLeft64(load64(s[n-8:]), 31) ^ uint64(n)
+	case n >= 4:
+		return (uint64(load32(s)) | uint64(load32(s[n-4:]))<<32) ^ uint64(n)<<61
+	default:
+		return uint64(s[0]) | uint64(s[n/2])<<8 | uint64(s[n-1])<<16 | uint64(n)<<24
+	}
+}
+
+func load64(s string) uint64 {
+	_ = s[7]
+	return uint64(s[0]) | uint64(s[1])<<8 | uint64(s[2])<<16 | uint64(s[3])<<24 |
+		uint64(s[4])<<32 | uint64(s[5])<<40 | uint64(s[6])<<48 | uint64(s[7])<<56
+}
+
+func load32(s string) uint32 {
+	_ = s[3]
+	return uint32(s[0]) | uint32(s[1])<<8 | uint32(s[2])<<16 | uint32(s[3])<<24
+}
+
+// flatSlot maps a segment to a slot of a 1<<bits table by multiplicative hashing.
+func flatSlot(s string, seed, bits uint32, full bool) uint32 {
+	var key uint64
+	if full {
+		// FNV-1a
+		key = 14695981039346656037
+		for i := 0; i < len(s); i++ {
+			key ^= uint64(s[i])
+			key *= 1099511628211
+		}
+	} else {
+		key = flatKey(s)
+	}
+	return uint32((key * (uint64(seed)*0x9E3779B97F4A7C15 | 1)) >> (64 - bits))
+}
+
+func (m *flatMatcher) staticChild(s *flatState, part string) int32 {
+	if s.slotBits == 0 {
+		for i := s.edgeStart; i < s.edgeEnd; i++ {
+			e := &m.edges[i]
+			if int(e.len) == len(part) && m.text[e.off:e.off+e.len] == part {
+				return e.state
+			}
+		}
+		return -1
+	}
+	slot := m.slots[s.slotStart+int32(flatSlot(part, s.seed, uint32(s.slotBits), s.fullHash))]
+	if slot == 0 {
+		return -1
+	}
+	e := &m.edges[slot-1]
+	if int(e.len) != len(part) || m.text[e.off:e.off+e.len] != part {
+		return -1
+	}
+	return e.state
+}
+
+// Backtracking stages of a frame, after its static child failed.
+const (
+	flatChildren uint8 = iota // mixed then constrained children, from next
+	flatParam
+	flatWild
+)
+
+// flatFrame is a backtracking point: a state whose remaining alternatives have not
+// been tried yet.
+type flatFrame struct {
+	state  int32
+	height int32
+	next   int32 // next mixed/constrained child (index into children)
+	saved  int32 // params length to restore before each alternative
+	stage  uint8
+}
+
+// Every frame consumes a segment before the next one is pushed, so a path of at most
+// MaxDepth segments needs at most MaxDepth+1 frames. The stack lives in the pooled
+// pathSegments, so matching does not allocate or clear it.
+const flatMaxFrames = MaxDepth + 1
+
+// match walks the automaton with the same precedence and backtracking as
+// frozenNode.search: Static > Mixed > Constrained > Param > Wildcard. Each loop
+// iteration enters one state; a frame is pushed only when the state has alternatives
+// beyond its static child.
+func (m *flatMatcher) match(segs *pathSegments, params *Params) *frozenNode {
+	parts := segs.parts
+	if len(parts) > MaxDepth {
+		return nil
+	}
+	if cap(segs.frames) < flatMaxFrames {
+		segs.frames = make([]flatFrame, flatMaxFrames)
+	}
+	stack := segs.frames[:flatMaxFrames]
+	top := -1
+	cur, height := int32(0), 0
+
+	for {
+		s := &m.states[cur]
+		entered := true
+		if s.spanSegs > 0 {
+			last := height + int(s.spanSegs) - 1
+			if last >= len(parts) {
+				entered = false
+			} else if start, end := segs.indices[height], segs.indices[last]+len(parts[last]); end-start != int(s.spanLen) ||
+				segs.match[start:end] != m.text[s.spanOff:s.spanOff+s.spanLen] {
+				entered = false
+			}
+			height += int(s.spanSegs)
+		}
+		if entered && (height == len(parts) || s.wildcard) {
+			if s.leaf != nil {
+				if s.wildcard && params != nil {
+					start := segs.indices[height]
+					if start < len(segs.path) && segs.path[start] == '/' {
+						start++
+					}
+					params.Add(s.name, segs.path[start:])
+				}
+				return s.leaf
+			}
+			if height == len(parts) && s.wild >= 0 {
+				cur = s.wild
+				continue
+			}
+			entered = false
+		}
+		if entered {
+			if s.alternatives {
+				top++
+				f := &stack[top]
+				f.state, f.height, f.next, f.stage = cur, int32(height), s.mixedStart, flatChildren
+				if params != nil {
+					f.saved = int32(len(params.Keys))
+				}
+			}
+			if s.edgeEnd > s.edgeStart {
+				if child := m.staticChild(s, parts[height]); child >= 0 {
+					cur = child
+					continue
+				}
+			}
+		}
+
+		// Backtrack: resume the innermost frame with an untried alternative.
+		for {
+			if top < 0 {
+				return nil
+			}
+			f := &stack[top]
+			fs := &m.states[f.state]
+			h := int(f.height)
+			if params != nil {
+				params.Keys = params.Keys[:f.saved]
+				params.Values = params.Values[:f.saved]
+			}
+			next := int32(-1)
+			switch f.stage {
+			case flatChildren:
+				for f.next < fs.consEnd && next < 0 {
+					if params != nil {
+						params.Keys = params.Keys[:f.saved]
+						params.Values = params.Values[:f.saved]
+					}
+					child := m.children[f.next]
+					cs := &m.states[child]
+					if f.next < fs.mixedEnd {
+						if cs.template.match(parts[h], segs.value(h), params) {
+							next = child
+						}
+					} else if value := segs.value(h); cs.constraint.match(value) {
+						if params != nil {
+							params.Add(cs.name, value)
+						}
+						next = child
+					}
+					f.next++
+				}
+				if next >= 0 {
+					height = h + 1
+					break
+				}
+				if params != nil {
+					// Drop what a failed template match captured before trying the param.
+					params.Keys = params.Keys[:f.saved]
+					params.Values = params.Values[:f.saved]
+				}
+				f.stage = flatParam
+				fallthrough
+			case flatParam:
+				f.stage = flatWild
+				if fs.param >= 0 {
+					if params != nil {
+						params.Add(m.states[fs.param].name, segs.value(h))
+					}
+					next, height = fs.param, h+1
+					if fs.wild < 0 {
+						top-- // last alternative: no need to come back
+					}
+					break
+				}
+				fallthrough
+			default:
+				top--
+				if fs.wild >= 0 {
+					next, height = fs.wild, h
+				}
+			}
+			if next >= 0 {
+				cur = next
+				break
+			}
+		}
+	}
+}
@@ -7,27 +7,41 @@ import (
 )
 
 type FrozenRouter struct {
-	table     frozenTable
-	hosts     map[string]*frozenTable
-	paramPool sync.Pool
-	partsPool sync.Pool
-	rwPool    sync.Pool
+	table        frozenTable
+	hosts        map[string]*frozenTable
+	hostPatterns []hostPattern
+	names        map[string]*RouteInfo
+	matcher      FrozenMatcher
+	paramPool    sync.Pool
+	partsPool    sync.Pool
+	rwPool       sync.Pool
+	sizes        poolSizes
 
 	NotFound         HandleFunc
 	MethodNotAllowed HandleFunc
 	PanicHandler     func(http.ResponseWriter, *http.Request, any)
 	IgnoreCase       bool
 	StrictSlash      bool
+	StrictParamSlash bool
 	UseRawPath       bool
+	UsePathValue     bool
+	// OPTIONS and HEAD policies; see the Router fields of the same names.
+	GlobalOPTIONS      HandleFunc
+	DisableAutoOPTIONS bool
+	SuppressHeadBody   bool
+	// Normalize is the path normalization policy; see Router.Normalize.
+	Normalize NormalizePolicy
 }
 
 type frozenTable struct {
 	roots       map[string]*frozenNode
 	static      map[string]map[string]HandleFunc
-	staticAllow map[string]string
+	staticAllow map[string]allowHeader
 	hasParams   map[string]bool
 	anyParams   bool
 	hasTrailing bool
+	mounts      []mount
+	matchers    map[string]frozenMatcher // per-method matcher over roots (see FrozenMatcher)
 }
 
 type frozenNode struct {
@@ -35,11 +49,18 @@ type frozenNode struct {
 	spanSegs       int
 	part           string
 	staticChildren *frozenStaticChildren
+	mixed          []*frozenNode
+	constrained    []*frozenNode
 	paramChild     *frozenNode
 	wildChild      *frozenNode
+	name           string
+	constraint     *paramConstraint
+	template       *segmentTemplate
 	pattern        string
 	handler        HandleFunc
 	hasParams      bool
+	route          *RouteInfo
+	versions       *versionSet
 }
 
 const frozenStaticThreshold = 4
@@ -100,16 +121,8 @@ func NewFrozenRouter() *FrozenRouter {
 		// Best-practice default: normalize trailing slashes with redirects.
 		StrictSlash: true,
 	}
-	fr.paramPool = sync.Pool{
-		New: func() interface{} {
-			return &Params{Keys: make([]string, 0, 6), Values: make([]string, 0, 6)}
-		},
-	}
-	fr.partsPool = sync.Pool{
-		New: func() interface{} {
-			return &pathSegments{parts: make([]string, 0, 20), indices: make([]int, 0, 21)}
-		},
-	}
+	fr.paramPool.New = fr.sizes.newParams
+	fr.partsPool.New = fr.sizes.newSegments
 	fr.rwPool = sync.Pool{
 		New: func() interface{} { return &paramRW{} },
 	}
@@ -120,7 +133,7 @@ func newFrozenTable() frozenTable {
 	return frozenTable{
 		roots:       make(map[string]*frozenNode),
 		static:      make(map[string]map[string]HandleFunc),
-		staticAllow: make(map[string]string),
+		staticAllow: make(map[string]allowHeader),
 		hasParams:   make(map[string]bool),
 	}
 }
@@ -128,13 +141,26 @@ func newFrozenTable() frozenTable {
 func (r *Router) Freeze() (*FrozenRouter, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	return r.freezeLocked(MatcherTree), nil
+}
 
+// freezeLocked builds a FrozenRouter from the current tables. Caller must hold r.mu.
+func (r *Router) freezeLocked(matcher FrozenMatcher) *FrozenRouter {
 	fr := NewFrozenRouter()
-	if ft := freezeTable(&r.table); ft != nil {
+	fr.matcher = matcher
+	fr.sizes.grow(int(r.sizes.params.Load()), int(r.sizes.depth.Load()))
+	fr.sizes.frames.Store(matcher == MatcherFlat)
+	fr.sizes.treeFrames.Store(matcher == MatcherTree)
+	if ft := freezeTable(&r.table, matcher); ft != nil {
 		fr.table = *ft
 	}
 	for host, table := range r.hosts {
-		fr.hosts[host] = freezeTable(table)
+		fr.hosts[host] = freezeTable(table, matcher)
+	}
+	fr.hostPatterns = append([]hostPattern(nil), r.hostPatterns...)
+	fr.names = make(map[string]*RouteInfo, len(r.names))
+	for name, info := range r.names {
+		fr.names[name] = info
 	}
 	if r.ignoreCaseSet {
 		fr.IgnoreCase = r.ignoreCaseEnabled
@@ -142,15 +168,21 @@ func (r *Router) Freeze() (*FrozenRouter, error) {
 		fr.IgnoreCase = r.IgnoreCase
 	}
 	fr.StrictSlash = r.StrictSlash
+	fr.StrictParamSlash = r.StrictParamSlash
 	fr.UseRawPath = r.UseRawPath
+	fr.UsePathValue = r.UsePathValue
 	fr.NotFound = r.NotFound
 	fr.MethodNotAllowed = r.MethodNotAllowed
 	fr.PanicHandler = r.PanicHandler
+	fr.GlobalOPTIONS = r.GlobalOPTIONS
+	fr.DisableAutoOPTIONS = r.DisableAutoOPTIONS
+	fr.SuppressHeadBody = r.SuppressHeadBody
+	fr.Normalize = r.Normalize
 
-	return fr, nil
+	return fr
 }
 
-func freezeTable(src *routeTable) *frozenTable {
+func freezeTable(src *routeTable, matcher FrozenMatcher) *frozenTable {
 	if src == nil {
 		return nil
 	}
@@ -160,8 +192,12 @@ func freezeTable(src *routeTable) *frozenTable {
 	ft.hasParams = cloneHasParams(src.hasParams)
 	ft.anyParams = src.anyParams
 	ft.hasTrailing = src.hasTrailing
+	ft.mounts = append([]mount(nil), src.mounts...)
+	ft.matchers = make(map[string]frozenMatcher, len(src.roots))
 	for method, root := range src.roots {
-		ft.roots[method] = freezeRoot(root)
+		froot := freezeRoot(root)
+		ft.roots[method] = froot
+		ft.matchers[method] = newFrozenMatcher(froot, matcher)
 	}
 	return &ft
 }
@@ -195,11 +231,11 @@ func cloneHasParams(src map[string]bool) map[string]bool {
 	return dst
 }
 
-func cloneStaticAllow(src map[string]string) map[string]string {
+func cloneStaticAllow(src map[string]allowHeader) map[string]allowHeader {
 	if src == nil {
 		return nil
 	}
-	dst := make(map[string]string, len(src))
+	dst := make(map[string]allowHeader, len(src))
 	for k, v := range src {
 		dst[k] = v
 	}
@@ -214,6 +250,8 @@ func freezeRoot(root *node) *frozenNode {
 		pattern:   root.pattern,
 		handler:   root.handler,
 		hasParams: root.hasParams,
+		route:     root.route,
+		versions:  root.versions,
 	}
 	if root.staticChildren != nil {
 		root.staticChildren.rangeFn(func(_ string, child *node) bool {
@@ -226,6 +264,12 @@ func freezeRoot(root *node) *frozenNode {
 			return true
 		})
 	}
+	for _, child := range root.mixed {
+		fn.mixed = append(fn.mixed, buildFrozenNode(child))
+	}
+	for _, child := range root.constrained {
+		fn.constrained = append(fn.constrained, buildFrozenNode(child))
+	}
 	if root.paramChild != nil {
 		fn.paramChild = buildFrozenNode(root.paramChild)
 	}
@@ -240,7 +284,7 @@ func compressStaticChain(start *node) ([]string, *node) {
 	cur := start
 	for cur != nil {
 		parts = append(parts, cur.part)
-		if cur.pattern != "" || cur.handler != nil || cur.paramChild != nil || cur.wildChild != nil {
+		if cur.pattern != "" || cur.handler != nil || cur.paramChild != nil || cur.wildChild != nil || len(cur.mixed) > 0 || len(cur.constrained) > 0 {
 			return parts, cur
 		}
 		if cur.staticChildren == nil || cur.staticChildren.len() != 1 {
@@ -262,6 +306,8 @@ func buildFrozenStatic(parts []string, end *node) *frozenNode {
 		pattern:    end.pattern,
 		handler:    end.handler,
 		hasParams:  end.hasParams,
+		route:      end.route,
+		versions:   end.versions,
 	}
 	if end.staticChildren != nil {
 		end.staticChildren.rangeFn(func(_ string, child *node) bool {
@@ -274,6 +320,12 @@ func buildFrozenStatic(parts []string, end *node) *frozenNode {
 			return true
 		})
 	}
+	for _, child := range end.mixed {
+		fn.mixed = append(fn.mixed, buildFrozenNode(child))
+	}
+	for _, child := range end.constrained {
+		fn.constrained = append(fn.constrained, buildFrozenNode(child))
+	}
 	if end.paramChild != nil {
 		fn.paramChild = buildFrozenNode(end.paramChild)
 	}
@@ -288,10 +340,15 @@ func buildFrozenNode(n *node) *frozenNode {
 		return nil
 	}
 	fn := &frozenNode{
-		part:      n.part,
-		pattern:   n.pattern,
-		handler:   n.handler,
-		hasParams: n.hasParams,
+		part:       n.part,
+		name:       n.name,
+		constraint: n.constraint,
+		template:   n.template,
+		pattern:    n.pattern,
+		handler:    n.handler,
+		hasParams:  n.hasParams,
+		route:      n.route,
+		versions:   n.versions,
 	}
 	if n.staticChildren != nil {
 		n.staticChildren.rangeFn(func(_ string, child *node) bool {
@@ -304,6 +361,12 @@ func buildFrozenNode(n *node) *frozenNode {
 			return true
 		})
 	}
+	for _, child := range n.mixed {
+		fn.mixed = append(fn.mixed, buildFrozenNode(child))
+	}
+	for _, child := range n.constrained {
+		fn.constrained = append(fn.constrained, buildFrozenNode(child))
+	}
 	if n.paramChild != nil {
 		fn.paramChild = buildFrozenNode(n.paramChild)
 	}
@@ -348,14 +411,19 @@ func (r *FrozenRouter) getPartsWithRaw(path, raw string) (*pathSegments, bool) {
 	return segs, true
 }
 
-func (r *FrozenRouter) tableForHost(host string) *frozenTable {
+// tableForHost returns the host table for host (nil if none) and the host
+// param captured by a matching host pattern.
+func (r *FrozenRouter) tableForHost(host string) (*frozenTable, string, string) {
 	if host == "" {
-		return &r.table
+		return nil, "", ""
+	}
+	if t, ok := r.hosts[host]; ok && t != nil && !isHostPattern(host) {
+		return t, "", ""
 	}
-	if t, ok := r.hosts[host]; ok && t != nil {
-		return t
+	if p, label := matchHostPattern(r.hostPatterns, host); p != nil {
+		return r.hosts[p.pattern], p.param, label
 	}
-	return &r.table
+	return nil, "", ""
 }
 
 func (r *FrozenRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
@@ -367,37 +435,42 @@ func (r *FrozenRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		}()
 	}
 
-	ctx, ok := prepareRouteContext(w, req, r.UseRawPath, r.IgnoreCase)
+	ctx, req, ok := prepareRouteContext(w, req, r.UseRawPath, r.IgnoreCase, r.Normalize)
 	if !ok {
 		return // Already responded (redirect or error)
 	}
+	if ctx.normalized != 0 {
+		nw := markNormalized(w, ctx.normalized)
+		r.serveRequest(nw, req, ctx)
+		unmarkNormalized(nw)
+		return
+	}
+	r.serveRequest(w, req, ctx)
+}
 
+// serveRequest routes a prepared request. See Router.serveRequest.
+func (r *FrozenRouter) serveRequest(w http.ResponseWriter, req *http.Request, ctx routeContext) {
 	host := normalizeHost(req.Host)
-	hostTable := r.tableForHost(host)
-	hasHost := host != "" && hostTable != &r.table
+	hostTable, hostParam, hostLabel := r.tableForHost(host)
 	defaultTable := &r.table
 
 	// Try host-specific table first
-	if hasHost {
-		if r.serveInTable(w, req, ctx.method, ctx.matchPath, ctx.paramPath, hostTable) {
-			return
-		}
-		if r.tryAlternateSlashInTable(w, req, ctx, hostTable) {
-			return
-		}
-		if r.handleMethodNotAllowedInTable(w, req, ctx, hostTable) {
+	if hostTable != nil {
+		if hostParam == "" {
+			if r.serveTable(w, req, ctx, hostTable) {
+				return
+			}
+		} else if r.serveTableWithHostParam(w, req, ctx, hostTable, hostParam, hostLabel) {
 			return
 		}
 	}
 
 	// Try default table
-	if r.serveInTable(w, req, ctx.method, ctx.matchPath, ctx.paramPath, defaultTable) {
-		return
-	}
-	if r.tryAlternateSlashInTable(w, req, ctx, defaultTable) {
+	if r.serveTable(w, req, ctx, defaultTable) {
 		return
 	}
-	if r.handleMethodNotAllowedInTable(w, req, ctx, defaultTable) {
+	if ctx.redirect {
+		ctx.redirectFn(w, req, ctx.paramPath)
 		return
 	}
 
@@ -408,6 +481,50 @@ func (r *FrozenRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	http.NotFound(w, req)
 }
 
+// serveTable routes the request within one table. See Router.serveTable.
+func (r *FrozenRouter) serveTable(w http.ResponseWriter, req *http.Request, ctx routeContext, table *frozenTable) bool {
+	if ctx.canonicalCase {
+		info := r.routeInTable(ctx.method, ctx.matchPath, ctx.paramPath, table)
+		if info == nil && ctx.redirect {
+			return false // serveRequest redirects once no table has the route
+		}
+		if info != nil {
+			if canonical, ok := canonicalCasePath(info.casing, ctx.paramPath, r.Normalize.CanonicalCase); ok {
+				return r.serveCanonicalInTable(w, req, ctx, table, canonical)
+			}
+			if ctx.redirect {
+				ctx.redirectFn(w, req, ctx.paramPath)
+				return true
+			}
+		}
+	}
+	return r.serveInTable(w, req, ctx.method, ctx.matchPath, ctx.paramPath, table) ||
+		r.tryAlternateSlashInTable(w, req, ctx, table) ||
+		r.serveMountInTable(w, req, ctx, table) ||
+		r.handleMethodNotAllowedInTable(w, req, ctx, table)
+}
+
+// serveTableWithHostParam is serveTable with the captured host label exposed via Param.
+func (r *FrozenRouter) serveTableWithHostParam(w http.ResponseWriter, req *http.Request, ctx routeContext, table *frozenTable, name, label string) bool {
+	params := r.paramPool.Get().(*Params)
+	params.Reset()
+	params.Add(name, label)
+	prw := r.rwPool.Get().(*paramRW)
+	prw.ResponseWriter = w
+	prw.params = params
+	if r.UsePathValue {
+		setPathValues(req, params)
+		prw.pathValues = true
+	}
+
+	served := r.serveTable(prw, req, ctx, table)
+
+	resetParamRW(prw)
+	r.rwPool.Put(prw)
+	r.paramPool.Put(params)
+	return served
+}
+
 func (r *FrozenRouter) tryAlternateSlashInTable(w http.ResponseWriter, req *http.Request, ctx routeContext, table *frozenTable) bool {
 	// Fast skip for the common "no trailing slash route exists" case.
 	if len(ctx.matchPath) > 1 && ctx.matchPath[len(ctx.matchPath)-1] != '/' && !table.hasTrailing {
@@ -418,12 +535,8 @@ func (r *FrozenRouter) tryAlternateSlashInTable(w http.ResponseWriter, req *http
 		return false
 	}
 	if r.StrictSlash {
-		if _, ok := r.allowedMethodsInTable(altMatch, table); ok {
-			altRedirect, ok := alternatePath(ctx.paramPath)
-			if ok && altRedirect != "" {
-				ctx.redirectFn(w, req, altRedirect)
-				return true
-			}
+		if allow, ok := r.allowedMethodsInTable(altMatch, table, true); ok {
+			return r.normalizeSlashInTable(w, req, ctx, table, altMatch, allow)
 		}
 		return false
 	}
@@ -431,17 +544,104 @@ func (r *FrozenRouter) tryAlternateSlashInTable(w http.ResponseWriter, req *http
 	return r.serveInTable(w, req, ctx.method, altMatch, altParam, table)
 }
 
+// routeInTable returns the route serving method at matchPath. See Router.routeInTable.
+func (r *FrozenRouter) routeInTable(method, matchPath, rawPath string, table *frozenTable) *RouteInfo {
+	segs, ok := r.getPartsWithRaw(matchPath, rawPath)
+	if !ok {
+		return nil
+	}
+	defer r.partsPool.Put(segs)
+	if len(segs.parts) > MaxDepth {
+		return nil
+	}
+	for i, m := range [...]string{method, http.MethodGet, MethodAny} {
+		if i == 1 && method != http.MethodHead {
+			continue
+		}
+		root, ok := table.matchers[m]
+		if !ok {
+			continue
+		}
+		if n := root.match(segs, nil); n != nil && n.handler != nil && (!r.StrictParamSlash || !r.StrictSlash || slashMatches(n.pattern, n.part, matchPath)) {
+			return n.route
+		}
+	}
+	return nil
+}
+
+// serveCanonicalInTable applies Normalize.Case to a path matching a route in another
+// casing than its normal form (see NormalizePolicy.Case).
+func (r *FrozenRouter) serveCanonicalInTable(w http.ResponseWriter, req *http.Request, ctx routeContext, table *frozenTable, canonical string) bool {
+	switch r.Normalize.foldCase() {
+	case NormalizeReject:
+		respondBadPath(w)
+		return true
+	case NormalizeRewrite:
+		if ctx.redirect {
+			break // the Clean redirect carries the casing
+		}
+		req = rewriteRequest(req, canonical, ctx.useRaw)
+		ctx.paramPath = canonical
+		ctx.canonicalCase = false
+		nw := markNormalized(w, NormalizedCase)
+		served := r.serveTable(nw, req, ctx, table)
+		unmarkNormalized(nw)
+		return served
+	}
+	ctx.redirectFn(w, req, canonical)
+	return true
+}
+
+// normalizeSlashInTable applies Normalize.TrailingSlash to a request only registered
+// with the other trailing slash (altMatch, allowing allow).
+func (r *FrozenRouter) normalizeSlashInTable(w http.ResponseWriter, req *http.Request, ctx routeContext, table *frozenTable, altMatch, allow string) bool {
+	altParam, ok := alternatePath(ctx.paramPath)
+	if !ok || altParam == "" {
+		return false
+	}
+	switch r.Normalize.trailingSlash() {
+	case NormalizePassThrough:
+		return false
+	case NormalizeReject:
+		respondBadPath(w)
+		return true
+	case NormalizeRewrite:
+		req = rewriteRequest(req, altParam, ctx.useRaw)
+		nw := markNormalized(w, NormalizedTrailingSlash)
+		if !r.serveInTable(nw, req, ctx.method, altMatch, altParam, table) {
+			respondMethodNotAllowed(nw, req, allow, r.MethodNotAllowed, r.GlobalOPTIONS, !r.DisableAutoOPTIONS)
+		}
+		unmarkNormalized(nw)
+		return true
+	default:
+		ctx.redirectFn(w, req, altParam)
+		return true
+	}
+}
+
+func (r *FrozenRouter) serveMountInTable(w http.ResponseWriter, req *http.Request, ctx routeContext, table *frozenTable) bool {
+	if len(table.mounts) == 0 {
+		return false
+	}
+	m := findMount(table.mounts, ctx.matchPath)
+	if m == nil {
+		return false
+	}
+	serveMount(w, req, m)
+	return true
+}
+
 func (r *FrozenRouter) handleMethodNotAllowedInTable(w http.ResponseWriter, req *http.Request, ctx routeContext, table *frozenTable) bool {
-	if allow, ok := r.allowedMethodsInTable(ctx.matchPath, table); ok {
-		return respondMethodNotAllowed(w, req, allow, r.MethodNotAllowed)
+	if allow, ok := r.allowedMethodsInTable(ctx.matchPath, table, r.StrictSlash && r.StrictParamSlash); ok {
+		return respondMethodNotAllowed(w, req, allow, r.MethodNotAllowed, r.GlobalOPTIONS, !r.DisableAutoOPTIONS)
 	}
 	if !r.StrictSlash {
 		if len(ctx.matchPath) > 1 && ctx.matchPath[len(ctx.matchPath)-1] != '/' && !table.hasTrailing {
 			return false
 		}
 		if altMatch, ok := alternatePath(ctx.matchPath); ok {
-			if allow, ok := r.allowedMethodsInTable(altMatch, table); ok {
-				return respondMethodNotAllowed(w, req, allow, r.MethodNotAllowed)
+			if allow, ok := r.allowedMethodsInTable(altMatch, table, false); ok {
+				return respondMethodNotAllowed(w, req, allow, r.MethodNotAllowed, r.GlobalOPTIONS, !r.DisableAutoOPTIONS)
 			}
 		}
 	}
@@ -449,13 +649,25 @@ func (r *FrozenRouter) handleMethodNotAllowedInTable(w http.ResponseWriter, req
 }
 
 func (r *FrozenRouter) serveInTable(w http.ResponseWriter, req *http.Request, method, matchPath, rawPath string, table *frozenTable) bool {
-	if method == http.MethodHead {
-		if r.serveMethodInTable(w, req, http.MethodHead, matchPath, rawPath, table) {
-			return true
-		}
+	if r.serveMethodInTable(w, req, method, matchPath, rawPath, table) {
+		return true
+	}
+	if method == http.MethodHead && r.serveHeadAsGet(w, req, matchPath, rawPath, table) {
+		return true
+	}
+	return method != MethodAny && r.serveMethodInTable(w, req, MethodAny, matchPath, rawPath, table)
+}
+
+// serveHeadAsGet serves HEAD with the GET route, discarding the body when
+// SuppressHeadBody is set.
+func (r *FrozenRouter) serveHeadAsGet(w http.ResponseWriter, req *http.Request, matchPath, rawPath string, table *frozenTable) (served bool) {
+	if !r.SuppressHeadBody {
 		return r.serveMethodInTable(w, req, http.MethodGet, matchPath, rawPath, table)
 	}
-	return r.serveMethodInTable(w, req, method, matchPath, rawPath, table)
+	hw := acquireHeadRW(w)
+	defer func() { releaseHeadRW(hw, served) }()
+	served = r.serveMethodInTable(hw, req, http.MethodGet, matchPath, rawPath, table)
+	return served
 }
 
 func (r *FrozenRouter) serveMethodInTable(w http.ResponseWriter, req *http.Request, method, matchPath, rawPath string, table *frozenTable) bool {
@@ -481,16 +693,17 @@ func (r *FrozenRouter) serveMethodInTable(w http.ResponseWriter, req *http.Reque
 	}
 	cleanupParts := func() { r.partsPool.Put(segs) }
 
-	root, ok := table.roots[method]
+	root, ok := table.matchers[method]
 	if !ok {
 		cleanupParts()
 		return false
 	}
 
-	node := root.search(segs, 0, nil)
-	if node != nil && node.handler != nil {
+	node := root.match(segs, nil)
+	if node != nil && node.handler != nil && (!r.StrictParamSlash || !r.StrictSlash || slashMatches(node.pattern, node.part, matchPath)) {
 		handler := node.handler
 		hasParams := node.hasParams
+		route := node.route
 
 		if !hasParams {
 			handler(w, req)
@@ -500,11 +713,16 @@ func (r *FrozenRouter) serveMethodInTable(w http.ResponseWriter, req *http.Reque
 
 		params := r.paramPool.Get().(*Params)
 		params.Reset()
-		_ = root.search(segs, 0, params)
+		_ = root.match(segs, params)
 
 		prw := r.rwPool.Get().(*paramRW)
 		prw.ResponseWriter = w
 		prw.params = params
+		prw.route = route
+		if r.UsePathValue {
+			setPathValues(req, params)
+			prw.pathValues = true
+		}
 
 		handler(prw, req)
 
@@ -519,10 +737,10 @@ func (r *FrozenRouter) serveMethodInTable(w http.ResponseWriter, req *http.Reque
 	return false
 }
 
-func (r *FrozenRouter) allowedMethodsInTable(matchPath string, table *frozenTable) (string, bool) {
+func (r *FrozenRouter) allowedMethodsInTable(matchPath string, table *frozenTable, exactSlash bool) (string, bool) {
 	if !table.anyParams {
 		if allow, ok := table.staticAllow[matchPath]; ok {
-			return allow, true
+			return allow.value(!r.DisableAutoOPTIONS), true
 		}
 		return "", false
 	}
@@ -545,7 +763,7 @@ func (r *FrozenRouter) allowedMethodsInTable(matchPath string, table *frozenTabl
 		if !has {
 			continue
 		}
-		root := table.roots[method]
+		root := table.matchers[method]
 		if root == nil {
 			continue
 		}
@@ -562,7 +780,7 @@ func (r *FrozenRouter) allowedMethodsInTable(matchPath string, table *frozenTabl
 				return "", false
 			}
 		}
-		if root.search(segs, 0, nil) != nil {
+		if n := root.match(segs, nil); n != nil && (!exactSlash || slashMatches(n.pattern, n.part, matchPath)) {
 			bits, custom = addAllowedMethod(method, bits, custom)
 		}
 	}
@@ -574,86 +792,164 @@ func (r *FrozenRouter) allowedMethodsInTable(matchPath string, table *frozenTabl
 	if bits == 0 && len(custom) == 0 {
 		return "", false
 	}
-	if bits&allowMethodGet != 0 {
-		bits |= allowMethodHead
-	}
-	bits |= allowMethodOptions
+	return allowValue(bits, custom, !r.DisableAutoOPTIONS), true
+}
 
-	return buildAllowHeader(bits, custom), true
+// Backtracking stages of a treeFrame, after the static child of its node failed.
+const (
+	treeChildren uint8 = iota // mixed then constrained children, from next
+	treeParam
+	treeWild
+)
+
+// treeFrame is a backtracking point of frozenNode.search: a node whose remaining
+// alternatives have not been tried yet.
+type treeFrame struct {
+	node   *frozenNode
+	height int32
+	next   int32 // next mixed, then constrained child
+	saved  int32 // params length to restore before each alternative
+	stage  uint8
+}
+
+// Like the flat matcher, every frame consumes a segment before the next one is pushed,
+// so MaxDepth+1 frames are enough. The stack lives in the pooled pathSegments.
+const treeMaxFrames = MaxDepth + 1
+
+// alternatives reports whether search may have to come back to n after its static child.
+func (n *frozenNode) alternatives() bool {
+	return len(n.mixed) > 0 || len(n.constrained) > 0 || n.paramChild != nil || n.wildChild != nil
 }
 
-func (n *frozenNode) search(segs *pathSegments, height int, params *Params) *frozenNode {
+// search finds the leaf matching segs with the precedence
+// Static > Mixed > Constrained > Param > Wildcard, backtracking when a branch fails
+// deeper down. It loops over an explicit stack instead of recursing: each iteration
+// enters one node, and a frame is pushed only for nodes with alternatives beyond
+// their static child.
+func (n *frozenNode) search(segs *pathSegments, params *Params) *frozenNode {
 	parts := segs.parts
-	if height > MaxDepth {
+	if len(parts) > MaxDepth {
 		return nil
 	}
-
-	if n.spanSegs > 0 {
-		if height+n.spanSegs > len(parts) {
-			return nil
-		}
-		start := segs.indices[height]
-		last := height + n.spanSegs - 1
-		end := segs.indices[last] + len(parts[last])
-		if segs.match[start:end] != n.staticSpan {
-			return nil
-		}
-		height += n.spanSegs
-	}
-
-	if height == len(parts) || (len(n.part) > 0 && n.part[0] == '*') {
-		if n.pattern == "" {
-			if height == len(parts) && n.wildChild != nil {
-				return n.wildChild.search(segs, height, params)
+	if cap(segs.treeFrames) < treeMaxFrames {
+		segs.treeFrames = make([]treeFrame, treeMaxFrames)
+	}
+	stack := segs.treeFrames[:treeMaxFrames]
+	top := -1
+	cur, height := n, 0
+
+	for {
+		entered := true
+		if cur.spanSegs > 0 {
+			last := height + cur.spanSegs - 1
+			if last >= len(parts) {
+				entered = false
+			} else if start, end := segs.indices[height], segs.indices[last]+len(parts[last]); segs.match[start:end] != cur.staticSpan {
+				entered = false
 			}
-			return nil
-		}
-		if len(n.part) > 0 && n.part[0] == '*' && params != nil {
-			start := segs.indices[height]
-			if start < len(segs.path) && segs.path[start] == '/' {
-				start++
+			height += cur.spanSegs
+		}
+		wild := len(cur.part) > 0 && cur.part[0] == '*'
+		if entered && (height == len(parts) || wild) {
+			if cur.pattern != "" {
+				if wild && params != nil {
+					start := segs.indices[height]
+					if start < len(segs.path) && segs.path[start] == '/' {
+						start++
+					}
+					params.Add(cur.name, segs.path[start:])
+				}
+				return cur
 			}
-			params.Add(n.part[1:], segs.path[start:])
-		}
-		return n
-	}
-
-	part := parts[height]
-
-	if n.staticChildren != nil {
-		if child := n.staticChildren.get(part); child != nil {
-			if res := child.search(segs, height, params); res != nil {
-				return res
+			if height == len(parts) && cur.wildChild != nil {
+				cur = cur.wildChild
+				continue
 			}
-		}
-	}
-
-	if child := n.paramChild; child != nil {
-		snapshot := 0
-		if params != nil {
-			snapshot = len(params.Keys)
-			start := segs.indices[height]
-			end := start + len(parts[height])
-			value := part
-			if start >= 0 && end <= len(segs.path) {
-				value = segs.path[start:end]
+			entered = false
+		}
+		if entered {
+			if cur.alternatives() {
+				top++
+				f := &stack[top]
+				f.node, f.height, f.next, f.stage = cur, int32(height), 0, treeChildren
+				if params != nil {
+					f.saved = int32(len(params.Keys))
+				}
+			}
+			if cur.staticChildren != nil {
+				if child := cur.staticChildren.get(parts[height]); child != nil {
+					cur = child
+					continue
+				}
 			}
-			params.Add(child.part[1:], value)
-		}
-		if res := child.search(segs, height+1, params); res != nil {
-			return res
-		}
-		if params != nil {
-			params.Keys = params.Keys[:snapshot]
-			params.Values = params.Values[:snapshot]
 		}
-	}
 
-	if child := n.wildChild; child != nil {
-		if res := child.search(segs, height, params); res != nil {
-			return res
+		// Backtrack: resume the innermost frame with an untried alternative.
+		for {
+			if top < 0 {
+				return nil
+			}
+			f := &stack[top]
+			fn := f.node
+			h := int(f.height)
+			var next *frozenNode
+			switch f.stage {
+			case treeChildren:
+				nm := len(fn.mixed)
+				for int(f.next) < nm+len(fn.constrained) && next == nil {
+					if params != nil {
+						params.Keys = params.Keys[:f.saved]
+						params.Values = params.Values[:f.saved]
+					}
+					if i := int(f.next); i < nm {
+						if child := fn.mixed[i]; child.template.match(parts[h], segs.value(h), params) {
+							next = child
+						}
+					} else if child, value := fn.constrained[i-nm], segs.value(h); child.constraint.match(value) {
+						if params != nil {
+							params.Add(child.name, value)
+						}
+						next = child
+					}
+					f.next++
+				}
+				if next != nil {
+					height = h + 1
+					break
+				}
+				f.stage = treeParam
+				fallthrough
+			case treeParam:
+				if params != nil {
+					params.Keys = params.Keys[:f.saved]
+					params.Values = params.Values[:f.saved]
+				}
+				f.stage = treeWild
+				if child := fn.paramChild; child != nil {
+					if params != nil {
+						params.Add(child.name, segs.value(h))
+					}
+					next, height = child, h+1
+					if fn.wildChild == nil {
+						top-- // last alternative: no need to come back
+					}
+					break
+				}
+				fallthrough
+			default:
+				if params != nil {
+					params.Keys = params.Keys[:f.saved]
+					params.Values = params.Values[:f.saved]
+				}
+				top--
+				if fn.wildChild != nil {
+					next, height = fn.wildChild, h
+				}
+			}
+			if next != nil {
+				cur = next
+				break
+			}
 		}
 	}
-
-	return nil
 }
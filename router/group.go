@@ -27,8 +27,21 @@ func (r *Router) Group(prefix string, mw ...Middleware) *Group {
 }
 
 // Host creates a host-specific route group.
+// The first label may be a wildcard ("*.example.com") or a param (":tenant.example.com")
+// matching exactly one label; a param is read with Param like path params.
+// Exact hosts win over patterns, and longer pattern suffixes win over shorter ones.
 func (r *Router) Host(host string) *Group {
-	return newGroup(r, normalizeHost(host), "", nil)
+	return newGroup(r, normalizeHostPattern(host), "", nil)
+}
+
+// With returns a group adding middlewares to the routes registered through it, for
+// middlewares meant for a single route:
+//
+//	r.With(bodyLimit(32 << 20)).POST("/upload", upload)
+//
+// Like Group, the chain is composed once at registration. See also RouteOptions.Middlewares.
+func (r *Router) With(mw ...Middleware) *Group {
+	return newGroup(r, "", "", mw)
 }
 
 // Group represents a nested routing group with its own prefix and middleware chain.
@@ -41,6 +54,7 @@ type Group struct {
 	host        string
 	prefix      string
 	middlewares []Middleware
+	version     string // API version of the group's routes (see Router.Version)
 }
 
 // Use appends middlewares to the group.
@@ -61,12 +75,38 @@ func (g *Group) Group(prefix string, mw ...Middleware) *Group {
 	combined := make([]Middleware, 0, len(g.middlewares)+len(mw))
 	combined = append(combined, g.middlewares...)
 	combined = append(combined, mw...)
-	return newGroup(g.router, g.host, joinPaths(g.prefix, cleanPrefix(prefix)), combined)
+	child := newGroup(g.router, g.host, joinPaths(g.prefix, cleanPrefix(prefix)), combined)
+	child.version = g.version
+	return child
+}
+
+// With returns a copy of the group adding middlewares, keeping its prefix, host and
+// version. The group itself is unchanged. See Router.With.
+func (g *Group) With(mw ...Middleware) *Group {
+	combined := make([]Middleware, 0, len(g.middlewares)+len(mw))
+	combined = append(combined, g.middlewares...)
+	combined = append(combined, mw...)
+	c := newGroup(g.router, g.host, g.prefix, combined)
+	c.version = g.version
+	return c
 }
 
 // Handle registers a route with the group's prefix and middlewares.
 func (g *Group) Handle(method, pattern string, handler HandleFunc) error {
-	return g.router.handle(g.host, method, joinPaths(g.prefix, pattern), handler, g.middlewares)
+	return g.router.handle(g.host, method, joinPaths(g.prefix, pattern), handler, g.middlewares, RouteOptions{Version: g.version})
+}
+
+// HandleWith registers a route with the group's prefix, middlewares and per-route options.
+func (g *Group) HandleWith(method, pattern string, handler HandleFunc, opts RouteOptions) error {
+	if opts.Version == "" {
+		opts.Version = g.version
+	}
+	return g.router.handle(g.host, method, joinPaths(g.prefix, pattern), handler, g.middlewares, opts)
+}
+
+// Any registers a route matching every method under the group.
+func (g *Group) Any(pattern string, handler HandleFunc) error {
+	return g.Handle(MethodAny, pattern, handler)
 }
 
 func (g *Group) GET(pattern string, handler HandleFunc) error {
@@ -0,0 +1,129 @@
+package router
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// headRW serves a HEAD request with a GET handler (see Router.SuppressHeadBody).
+// It holds back the status, counts and discards the body, and sets Content-Length
+// from the count when the handler returns, unless the handler set one or flushed.
+type headRW struct {
+	http.ResponseWriter
+	status    int
+	written   int64
+	committed bool
+}
+
+var headRWPool = sync.Pool{
+	New: func() interface{} { return &headRW{} },
+}
+
+func acquireHeadRW(w http.ResponseWriter) *headRW {
+	hw := headRWPool.Get().(*headRW)
+	hw.ResponseWriter = w
+	return hw
+}
+
+// releaseHeadRW finishes the response if the handler ran and returns hw to the pool.
+// It is deferred, so it also runs when the handler panics: a status the handler already
+// set is sent as a GET would have sent it, otherwise the response is left to the
+// PanicHandler.
+func releaseHeadRW(hw *headRW, served bool) {
+	if served || hw.status != 0 {
+		hw.commit(true)
+	}
+	*hw = headRW{}
+	headRWPool.Put(hw)
+}
+
+func (w *headRW) WriteHeader(code int) {
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code) // informational responses pass through
+		return
+	}
+	if w.status == 0 && !w.committed {
+		w.status = code
+	}
+}
+
+func (w *headRW) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.written += int64(len(b))
+	return len(b), nil
+}
+
+// ReadFrom discards r without copying it through a buffer of the caller.
+func (w *headRW) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := io.Copy(io.Discard, r)
+	w.written += n
+	return n, err
+}
+
+// Flush sends the headers now; Content-Length is then left to the handler.
+func (w *headRW) Flush() {
+	w.commit(false)
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hands the connection to the handler; nothing is written for it afterwards.
+func (w *headRW) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.committed = true
+	}
+	return conn, rw, err
+}
+
+func (w *headRW) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+func (w *headRW) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *headRW) commit(final bool) {
+	if w.committed {
+		return
+	}
+	w.committed = true
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	h := w.ResponseWriter.Header()
+	if final && bodyAllowedForStatus(status) && len(h["Content-Length"]) == 0 && len(h["Transfer-Encoding"]) == 0 {
+		h["Content-Length"] = []string{strconv.FormatInt(w.written, 10)}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// bodyAllowedForStatus mirrors net/http: 1xx, 204 and 304 responses have no body.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
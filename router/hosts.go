@@ -0,0 +1,134 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+)
+
+// hostPattern is a host matching any single leading label:
+// "*.example.com" (anonymous) or ":tenant.example.com" (label captured as a param).
+type hostPattern struct {
+	pattern string // normalized pattern, also the key of the host table
+	suffix  string // ".example.com"
+	param   string // "tenant"; empty for '*'
+}
+
+// isHostPattern reports whether host starts with a wildcard or param label.
+// IPv6 literals ("::1") are not patterns.
+func isHostPattern(host string) bool {
+	if host == "" {
+		return false
+	}
+	return host[0] == '*' || (host[0] == ':' && len(host) > 1 && isParamNameChar(host[1]))
+}
+
+// normalizeHostPattern normalizes the fixed part of a host pattern and keeps the
+// leading label (e.g. a param name) as written. Plain hosts go through normalizeHost.
+func normalizeHostPattern(host string) string {
+	host = strings.TrimSpace(host)
+	if !isHostPattern(host) {
+		return normalizeHost(host)
+	}
+	dot := strings.IndexByte(host, '.')
+	if dot < 0 {
+		return host
+	}
+	return host[:dot] + normalizeHost(host[dot:])
+}
+
+func parseHostPattern(host string) (hostPattern, error) {
+	dot := strings.IndexByte(host, '.')
+	if dot < 0 || dot == len(host)-1 {
+		return hostPattern{}, fmt.Errorf("host pattern needs a fixed suffix (e.g., *.example.com): %s", host)
+	}
+	p := hostPattern{pattern: host, suffix: host[dot:]}
+	label := host[:dot]
+	switch {
+	case label == "*":
+	case label[0] == ':' && len(label) > 1:
+		p.param = label[1:]
+		for i := 0; i < len(p.param); i++ {
+			if !isParamNameChar(p.param[i]) {
+				return hostPattern{}, fmt.Errorf("invalid host param name in %s", host)
+			}
+		}
+	default:
+		return hostPattern{}, fmt.Errorf("only the first host label can be a wildcard or param: %s", host)
+	}
+	if strings.ContainsAny(p.suffix, "*:") {
+		return hostPattern{}, fmt.Errorf("only the first host label can be a wildcard or param: %s", host)
+	}
+	return p, nil
+}
+
+// match reports whether host is exactly one label followed by the suffix.
+func (p *hostPattern) match(host string) (string, bool) {
+	if len(host) <= len(p.suffix) || !strings.HasSuffix(host, p.suffix) {
+		return "", false
+	}
+	label := host[:len(host)-len(p.suffix)]
+	if strings.IndexByte(label, '.') >= 0 {
+		return "", false
+	}
+	return label, true
+}
+
+// insertHostPattern keeps patterns ordered by suffix length, longest (most specific) first.
+func insertHostPattern(patterns []hostPattern, p hostPattern) ([]hostPattern, error) {
+	i := 0
+	for i < len(patterns) && len(patterns[i].suffix) >= len(p.suffix) {
+		if patterns[i].suffix == p.suffix {
+			return patterns, fmt.Errorf("conflict: host pattern '%s' conflicts with existing '%s'", p.pattern, patterns[i].pattern)
+		}
+		i++
+	}
+	patterns = append(patterns, hostPattern{})
+	copy(patterns[i+1:], patterns[i:])
+	patterns[i] = p
+	return patterns, nil
+}
+
+func removeHostPattern(patterns []hostPattern, pattern string) []hostPattern {
+	for i := range patterns {
+		if patterns[i].pattern == pattern {
+			return append(patterns[:i], patterns[i+1:]...)
+		}
+	}
+	return patterns
+}
+
+// matchHostPattern returns the most specific pattern matching host and the captured label.
+func matchHostPattern(patterns []hostPattern, host string) (*hostPattern, string) {
+	for i := range patterns {
+		if label, ok := patterns[i].match(host); ok {
+			return &patterns[i], label
+		}
+	}
+	return nil, ""
+}
+
+// validateHostParam rejects path params that shadow the host param.
+func validateHostParam(host string, parts []string) error {
+	if !isHostPattern(host) {
+		return nil
+	}
+	p, err := parseHostPattern(host)
+	if err != nil || p.param == "" {
+		return err
+	}
+	for _, part := range parts {
+		if !isDynamicPart(part) {
+			continue
+		}
+		seg, err := parseSegment(part)
+		if err != nil {
+			continue
+		}
+		for _, name := range seg.params() {
+			if name == p.param {
+				return fmt.Errorf("conflict: param '%s' duplicates host param in %s", name, host)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// ManifestVersion is the format version written by ExportManifest.
+const ManifestVersion = 1
+
+// RouteManifest is the serializable form of a FrozenRouter: its flags, matcher and
+// every route with the ID of its handler. Handlers themselves, middlewares and hooks
+// (NotFound, MethodNotAllowed, PanicHandler, GlobalOPTIONS, ...) are not part of it.
+type RouteManifest struct {
+	Version     int    `json:"version"`
+	Matcher     string `json:"matcher"` // "tree" or "flat" (see FrozenMatcher)
+	IgnoreCase  bool   `json:"ignoreCase"`
+	StrictSlash bool   `json:"strictSlash"`
+	// StrictParamSlash mirrors the Router field.
+	StrictParamSlash bool `json:"strictParamSlash,omitempty"`
+	UseRawPath       bool `json:"useRawPath"`
+	UsePathValue     bool `json:"usePathValue"`
+	// DisableAutoOPTIONS and SuppressHeadBody mirror the Router fields.
+	DisableAutoOPTIONS bool `json:"disableAutoOptions"`
+	SuppressHeadBody   bool `json:"suppressHeadBody"`
+	// Normalize is the path normalization policy (see Router.Normalize).
+	Normalize NormalizePolicy `json:"normalize"`
+	// Routes are listed per host (default table first), then per method, in trie order.
+	// The order keeps constrained siblings in registration order, which decides their
+	// precedence, so a loaded manifest matches exactly like the exported router.
+	Routes []ManifestRoute `json:"routes"`
+}
+
+// ManifestRoute is one route of a RouteManifest.
+type ManifestRoute struct {
+	Method  string `json:"method"` // "*" for Any routes and mounts
+	Host    string `json:"host,omitempty"`
+	Pattern string `json:"pattern"`
+	Name    string `json:"name,omitempty"`
+	Kind    string `json:"kind"` // see RouteKind
+	// Handler keys the handler registry: the route name, or "METHOD host/pattern"
+	// (e.g. "GET /users/:id") for unnamed routes.
+	Handler string    `json:"handler"`
+	Doc     *RouteDoc `json:"doc,omitempty"`
+	// Consumes and Produces are the declared media types (see RouteOptions).
+	Consumes []string `json:"consumes,omitempty"`
+	Produces []string `json:"produces,omitempty"`
+	// Versions lists the API versions of a versioned route (see Router.Version),
+	// served by the handlers "Handler@VERSION" and selected by Versioning.
+	Versions   []string            `json:"versions,omitempty"`
+	Versioning *ManifestVersioning `json:"versioning,omitempty"`
+	// PathValue marks routes registered with ServeMux syntax, whose params are also
+	// set on req.PathValue (see Router.HandleFunc).
+	PathValue bool `json:"pathValue,omitempty"`
+}
+
+// ManifestVersioning is the serializable part of VersionOptions. NotAcceptable is not
+// kept: loaded routes answer unserved versions with an empty 406.
+type ManifestVersioning struct {
+	MediaType string `json:"mediaType,omitempty"`
+	Header    string `json:"header,omitempty"`
+	Query     string `json:"query,omitempty"`
+	Default   string `json:"default,omitempty"`
+}
+
+// Manifest describes the compiled router. The result is deterministic: freezing the
+// same registrations twice yields equal manifests.
+func (r *FrozenRouter) Manifest() RouteManifest {
+	m := RouteManifest{
+		Version:            ManifestVersion,
+		Matcher:            r.matcher.String(),
+		IgnoreCase:         r.IgnoreCase,
+		StrictSlash:        r.StrictSlash,
+		StrictParamSlash:   r.StrictParamSlash,
+		UseRawPath:         r.UseRawPath,
+		UsePathValue:       r.UsePathValue,
+		DisableAutoOPTIONS: r.DisableAutoOPTIONS,
+		SuppressHeadBody:   r.SuppressHeadBody,
+		Normalize:          r.Normalize,
+		Routes:             []ManifestRoute{},
+	}
+	hosts := make([]string, 0, len(r.hosts))
+	for host := range r.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	seen := make(map[*RouteInfo]bool)
+	m.Routes = appendManifestTable(m.Routes, seen, &r.table)
+	for _, host := range hosts {
+		m.Routes = appendManifestTable(m.Routes, seen, r.hosts[host])
+	}
+	return m
+}
+
+// ExportManifest encodes Manifest as indented JSON, suitable for diffing in CI.
+func (r *FrozenRouter) ExportManifest() ([]byte, error) {
+	return json.MarshalIndent(r.Manifest(), "", "  ")
+}
+
+// LoadManifest decodes a manifest written by ExportManifest and builds it (see Build).
+func LoadManifest(data []byte, handlers map[string]HandleFunc) (*FrozenRouter, error) {
+	var m RouteManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	return m.Build(handlers)
+}
+
+// Build registers every route of the manifest with the handler registered under its
+// Handler ID and freezes the result with the recorded matcher and flags. Handlers are
+// used as given: wrap them with any middlewares before building.
+func (m RouteManifest) Build(handlers map[string]HandleFunc) (*FrozenRouter, error) {
+	if m.Version != ManifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version: %d", m.Version)
+	}
+	matcher, ok := parseFrozenMatcher(m.Matcher)
+	if !ok {
+		return nil, fmt.Errorf("unknown frozen matcher: %s", m.Matcher)
+	}
+	r := NewRouter()
+	r.IgnoreCase = m.IgnoreCase
+	r.StrictSlash = m.StrictSlash
+	r.StrictParamSlash = m.StrictParamSlash
+	r.UseRawPath = m.UseRawPath
+	r.UsePathValue = m.UsePathValue
+	r.DisableAutoOPTIONS = m.DisableAutoOPTIONS
+	r.SuppressHeadBody = m.SuppressHeadBody
+	r.Normalize = m.Normalize
+	for _, route := range m.Routes {
+		if err := buildManifestRoute(r, route, handlers); err != nil {
+			return nil, err
+		}
+	}
+	return r.FreezeWith(FreezeOptions{Matcher: matcher})
+}
+
+func appendManifestTable(dst []ManifestRoute, seen map[*RouteInfo]bool, table *frozenTable) []ManifestRoute {
+	if table == nil {
+		return dst
+	}
+	methods := make([]string, 0, len(table.roots))
+	for method := range table.roots {
+		methods = append(methods, method)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methodLess(methods[i], methods[j]) })
+
+	var leaves []*frozenNode
+	for _, method := range methods {
+		leaves = collectManifestNode(leaves, table.roots[method])
+	}
+	for i := range table.mounts {
+		leaves = append(leaves, &frozenNode{route: table.mounts[i].route})
+	}
+	for _, leaf := range leaves {
+		// Variants of one route (optional segments) share a *RouteInfo: list it once.
+		if seen[leaf.route] {
+			continue
+		}
+		seen[leaf.route] = true
+		dst = append(dst, manifestRoute(leaf.route, leaf.versions))
+	}
+	return dst
+}
+
+// collectManifestNode collects the leaves under n in search order, with static children
+// sorted by part.
+func collectManifestNode(dst []*frozenNode, n *frozenNode) []*frozenNode {
+	if n == nil {
+		return dst
+	}
+	if n.route != nil {
+		dst = append(dst, n)
+	}
+	if n.staticChildren != nil {
+		var children []frozenStaticChild
+		if n.staticChildren.m != nil {
+			for part, child := range n.staticChildren.m {
+				children = append(children, frozenStaticChild{part: part, node: child})
+			}
+		} else {
+			children = append(children, n.staticChildren.small...)
+		}
+		sort.Slice(children, func(i, j int) bool { return children[i].part < children[j].part })
+		for _, c := range children {
+			dst = collectManifestNode(dst, c.node)
+		}
+	}
+	for _, child := range n.mixed {
+		dst = collectManifestNode(dst, child)
+	}
+	for _, child := range n.constrained {
+		dst = collectManifestNode(dst, child)
+	}
+	dst = collectManifestNode(dst, n.paramChild)
+	return collectManifestNode(dst, n.wildChild)
+}
+
+func manifestRoute(info *RouteInfo, versions *versionSet) ManifestRoute {
+	handler := info.Name
+	if handler == "" {
+		handler = info.Method + " " + info.Host + info.Pattern
+	}
+	route := ManifestRoute{
+		Method:    info.Method,
+		Host:      info.Host,
+		Pattern:   info.Pattern,
+		Name:      info.Name,
+		Kind:      info.Kind.String(),
+		Handler:   handler,
+		Doc:       info.Doc,
+		Consumes:  info.Consumes,
+		Produces:  info.Produces,
+		PathValue: info.pathValues,
+	}
+	if versions != nil {
+		route.Versions = append([]string(nil), versions.versions...)
+		route.Versioning = &ManifestVersioning{
+			MediaType: versions.opts.MediaType,
+			Header:    versions.opts.Header,
+			Query:     versions.opts.Query,
+			Default:   versions.opts.Default,
+		}
+	}
+	return route
+}
+
+func buildManifestRoute(r *Router, route ManifestRoute, handlers map[string]HandleFunc) error {
+	handler := func(id string) (HandleFunc, error) {
+		if h := handlers[id]; h != nil {
+			return h, nil
+		}
+		return nil, fmt.Errorf("missing handler: %s", id)
+	}
+	if route.Kind == RouteMount.String() {
+		h, err := handler(route.Handler)
+		if err != nil {
+			return err
+		}
+		if err := r.mount(route.Host, cleanPrefix(route.Pattern), http.HandlerFunc(h), nil); err != nil {
+			return fmt.Errorf("%s: %v", route.Handler, err)
+		}
+		return nil
+	}
+	if len(route.Versions) == 0 {
+		h, err := handler(route.Handler)
+		if err != nil {
+			return err
+		}
+		opts := RouteOptions{Name: route.Name, Doc: decodedDoc(route.Doc), Consumes: route.Consumes, Produces: route.Produces, pathValues: route.PathValue}
+		if err := r.handle(route.Host, route.Method, route.Pattern, h, nil, opts); err != nil {
+			return fmt.Errorf("%s: %v", route.Handler, err)
+		}
+		return nil
+	}
+	r.Versioning = VersionOptions{}
+	if v := route.Versioning; v != nil {
+		r.Versioning = VersionOptions{MediaType: v.MediaType, Header: v.Header, Query: v.Query, Default: v.Default}
+	}
+	for i, version := range route.Versions {
+		id := route.Handler + "@" + version
+		h, err := handler(id)
+		if err != nil {
+			return err
+		}
+		opts := RouteOptions{Version: version, Consumes: route.Consumes, Produces: route.Produces, pathValues: route.PathValue}
+		if i == 0 {
+			opts.Name, opts.Doc = route.Name, decodedDoc(route.Doc)
+		}
+		if err := r.handle(route.Host, route.Method, route.Pattern, h, nil, opts); err != nil {
+			return fmt.Errorf("%s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// decodedDoc maps the JSON nulls of a decoded doc back to nil schemas (no body).
+func decodedDoc(d *RouteDoc) *RouteDoc {
+	if d == nil {
+		return nil
+	}
+	doc := *d
+	if string(doc.RequestBody) == "null" {
+		doc.RequestBody = nil
+	}
+	if doc.Responses != nil {
+		doc.Responses = make(map[int]json.RawMessage, len(d.Responses))
+		for code, schema := range d.Responses {
+			if string(schema) == "null" {
+				schema = nil
+			}
+			doc.Responses[code] = schema
+		}
+	}
+	return &doc
+}
@@ -0,0 +1,163 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// mount forwards a whole subtree (every method, every sub-path) to a foreign handler.
+type mount struct {
+	prefix  string // match form: canonical, no trailing slash, lowercased with IgnoreCase
+	handler HandleFunc
+	route   *RouteInfo
+}
+
+// Mount routes every method and every sub-path under prefix to h with the prefix
+// stripped from URL.Path and URL.RawPath (like http.StripPrefix). Explicit routes
+// under the prefix take priority. Router middlewares are applied to h.
+//
+// Forwarded requests are shallow copies with a new URL, so each one allocates.
+func (r *Router) Mount(prefix string, h http.Handler) error {
+	return r.mount("", cleanPrefix(prefix), h, nil)
+}
+
+// Mount mounts h under the group's prefix joined with prefix, applying group middlewares.
+func (g *Group) Mount(prefix string, h http.Handler) error {
+	if g.version != "" {
+		return fmt.Errorf("mounts cannot be versioned: %s", prefix)
+	}
+	return g.router.mount(g.host, joinPaths(g.prefix, cleanPrefix(prefix)), h, g.middlewares)
+}
+
+func (r *Router) mount(host, prefix string, h http.Handler, groupMws []Middleware) error {
+	if h == nil {
+		return fmt.Errorf("nil handler for mount: %s", prefix)
+	}
+	if len(prefix) > MaxPathLength {
+		return fmt.Errorf("pattern too long: %s", prefix)
+	}
+	for _, part := range strings.Split(prefix, "/") {
+		if isDynamicPart(part) || strings.ContainsAny(part, "?\x00\r\n") {
+			return fmt.Errorf("mount prefix must be static: %s", prefix)
+		}
+	}
+
+	handler, err := applyMiddlewares(h.ServeHTTP, groupMws)
+	if err != nil {
+		return err
+	}
+	r.mu.RLock()
+	routerMws := make([]Middleware, len(r.middlewares))
+	copy(routerMws, r.middlewares)
+	r.mu.RUnlock()
+	if handler, err = applyMiddlewares(handler, routerMws); err != nil {
+		return err
+	}
+
+	matchPrefix := prefix
+	if r.lockIgnoreCase() {
+		matchPrefix = lowerASCII(prefix)
+	}
+	host = normalizeHostPattern(host)
+	pattern := prefix
+	if pattern == "" {
+		pattern = "/"
+	}
+	m := mount{
+		prefix:  matchPrefix,
+		handler: handler,
+		route:   &RouteInfo{Method: MethodAny, Host: host, Pattern: pattern, Kind: RouteMount},
+	}
+	if len(groupMws)+len(routerMws) > 0 {
+		m.handler = withRoute(m.route, handler)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	table, err := r.tableForHostLocked(host)
+	if err != nil {
+		return err
+	}
+	for _, existing := range table.mounts {
+		if existing.prefix == m.prefix {
+			return fmt.Errorf("duplicate mount: %s", pattern)
+		}
+	}
+	table.mounts = insertMount(table.mounts, m)
+	r.routesCount++
+	r.publishLocked()
+	return nil
+}
+
+// insertMount keeps mounts ordered by prefix length, longest first.
+func insertMount(mounts []mount, m mount) []mount {
+	i := 0
+	for i < len(mounts) && len(mounts[i].prefix) >= len(m.prefix) {
+		i++
+	}
+	mounts = append(mounts, mount{})
+	copy(mounts[i+1:], mounts[i:])
+	mounts[i] = m
+	return mounts
+}
+
+// findMount returns the mount owning matchPath, if any.
+func findMount(mounts []mount, matchPath string) *mount {
+	for i := range mounts {
+		p := mounts[i].prefix
+		if p == "" || matchPath == p || (strings.HasPrefix(matchPath, p) && matchPath[len(p)] == '/') {
+			return &mounts[i]
+		}
+	}
+	return nil
+}
+
+// serveMount strips the mount prefix and forwards the request.
+func serveMount(w http.ResponseWriter, req *http.Request, m *mount) {
+	u := *req.URL
+	u.Path, _ = trimMountPrefix(req.URL.Path, m.prefix)
+	if req.URL.RawPath != "" {
+		raw, ok := trimMountPrefix(req.URL.RawPath, m.prefix)
+		if ok {
+			u.RawPath = raw
+		} else {
+			u.RawPath = ""
+		}
+	}
+	r2 := new(http.Request)
+	*r2 = *req
+	r2.URL = &u
+	m.handler(w, r2)
+}
+
+// trimMountPrefix removes prefix (ASCII case-insensitive) and keeps a leading '/'.
+func trimMountPrefix(p, prefix string) (string, bool) {
+	if len(p) < len(prefix) || !equalFoldASCII(p[:len(prefix)], prefix) {
+		return p, false
+	}
+	p = p[len(prefix):]
+	if p == "" {
+		return "/", true
+	}
+	return p, true
+}
+
+func equalFoldASCII(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		ca, cb := a[i], b[i]
+		if ca >= 'A' && ca <= 'Z' {
+			ca += 'a' - 'A'
+		}
+		if cb >= 'A' && cb <= 'Z' {
+			cb += 'a' - 'A'
+		}
+		if ca != cb {
+			return false
+		}
+	}
+	return true
+}
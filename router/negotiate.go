@@ -0,0 +1,171 @@
+package router
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// negotiation enforces the media types a route consumes and produces
+// (see RouteOptions.Consumes and RouteOptions.Produces).
+type negotiation struct {
+	consumes      []string
+	produces      []string
+	acceptBody    string // Accept-Post/Accept-Patch value of 415 responses
+	unsupported   HandleFunc
+	notAcceptable HandleFunc
+}
+
+// negotiation validates and normalizes the declared media types and captures the 415
+// and 406 hooks. It returns nil when the route declares none.
+func (r *Router) negotiation(consumes, produces []string) (*negotiation, error) {
+	if len(consumes)+len(produces) == 0 {
+		return nil, nil
+	}
+	n := &negotiation{unsupported: r.UnsupportedMediaType, notAcceptable: r.NotAcceptable}
+	var err error
+	if n.consumes, err = normalizeMediaTypes(consumes); err != nil {
+		return nil, err
+	}
+	if n.produces, err = normalizeMediaTypes(produces); err != nil {
+		return nil, err
+	}
+	n.acceptBody = strings.Join(n.consumes, ", ")
+	return n, nil
+}
+
+func normalizeMediaTypes(types []string) ([]string, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+	out := make([]string, len(types))
+	for i, t := range types {
+		mediaType, _, err := mime.ParseMediaType(t)
+		if err != nil || strings.Count(mediaType, "/") != 1 {
+			return nil, fmt.Errorf("invalid media type: %q", t)
+		}
+		out[i] = mediaType
+	}
+	return out, nil
+}
+
+// wrap checks Content-Type and Accept before calling next, answering 415 Unsupported
+// Media Type or 406 Not Acceptable like the router answers 405.
+func (n *negotiation) wrap(next HandleFunc) HandleFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if len(n.consumes) > 0 && hasBody(req) && !n.consumable(req.Header.Get("Content-Type")) {
+			n.respondUnsupported(w, req)
+			return
+		}
+		if len(n.produces) > 0 && len(req.Header.Values("Accept")) > 0 &&
+			NegotiateContentType(req, n.produces...) == "" {
+			if n.notAcceptable != nil {
+				n.notAcceptable(w, req)
+				return
+			}
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		next(w, req)
+	}
+}
+
+func (n *negotiation) consumable(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	if mediaType == "" {
+		return false
+	}
+	for _, t := range n.consumes {
+		if mediaRangeSpecificity(t, mediaType) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// respondUnsupported writes the 415 response, listing the accepted types in
+// Accept-Post or Accept-Patch (RFC 9110, section 15.5.16).
+func (n *negotiation) respondUnsupported(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Set("Accept-Post", n.acceptBody)
+	case http.MethodPatch:
+		w.Header().Set("Accept-Patch", n.acceptBody)
+	}
+	if n.unsupported != nil {
+		n.unsupported(w, req)
+		return
+	}
+	w.WriteHeader(http.StatusUnsupportedMediaType)
+}
+
+// hasBody reports whether req carries (or declares) content.
+func hasBody(req *http.Request) bool {
+	return req.ContentLength != 0 || len(req.Header.Values("Content-Type")) > 0
+}
+
+// NegotiateContentType returns the offer the request's Accept header prefers, or ""
+// when it accepts none of them. Without an Accept header the first offer is returned.
+// Offers are concrete media types ("application/json"); ranges in Accept ("text/*",
+// "*/*") and q-values are honored, and ties go to the earlier offer. It does not allocate.
+func NegotiateContentType(req *http.Request, offers ...string) string {
+	accept := req.Header.Values("Accept")
+	if len(accept) == 0 {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the q-value the most specific matching range of Accept gives
+// to offer (0 when none matches).
+func acceptQuality(accept []string, offer string) float64 {
+	q, specificity := 0.0, 0
+	for _, header := range accept {
+		for header != "" {
+			var mediaRange string
+			mediaRange, header, _ = strings.Cut(header, ",")
+			mediaType, params, _ := strings.Cut(mediaRange, ";")
+			if s := mediaRangeSpecificity(strings.TrimSpace(mediaType), offer); s > specificity {
+				q, specificity = qValue(params), s
+			}
+		}
+	}
+	return q
+}
+
+// mediaRangeSpecificity reports how closely the media range matches mediaType:
+// 3 for the same type, 2 for "type/*", 1 for "*/*" and 0 for no match. Offers that are
+// ranges themselves ("application/*") match like concrete types of that range.
+func mediaRangeSpecificity(mediaRange, mediaType string) int {
+	rangeType, rangeSub, ok := strings.Cut(mediaRange, "/")
+	if !ok {
+		return 0
+	}
+	typ, sub, ok := strings.Cut(mediaType, "/")
+	if !ok {
+		return 0
+	}
+	switch {
+	case rangeType == "*" && rangeSub == "*":
+		return 1
+	case !strings.EqualFold(rangeType, typ) && typ != "*":
+		return 0
+	case rangeSub == "*":
+		return 2
+	case strings.EqualFold(rangeSub, sub) || sub == "*":
+		return 3
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,266 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+)
+
+// NormalizeMode selects what the router does with a request path that is not in
+// normal form (see NormalizePolicy).
+type NormalizeMode uint8
+
+const (
+	// NormalizeDefault keeps the router's usual behavior for that normalization.
+	NormalizeDefault NormalizeMode = iota
+	// NormalizeRedirect answers 301 (308 for methods other than GET and HEAD) with
+	// the normalized path.
+	NormalizeRedirect
+	// NormalizeRewrite serves the request as if the normalized path had been sent:
+	// handlers see a shallow copy of the request with the rewritten URL.
+	NormalizeRewrite
+	// NormalizeReject answers 400 Bad Request.
+	NormalizeReject
+	// NormalizePassThrough leaves the path alone: unclean paths and slash mismatches
+	// are matched as sent, and IgnoreCase matches without changing the path. For Clean,
+	// "." and ".." segments are still rejected, so they never reach a param.
+	NormalizePassThrough
+)
+
+func (m NormalizeMode) String() string {
+	switch m {
+	case NormalizeDefault:
+		return "default"
+	case NormalizeRedirect:
+		return "redirect"
+	case NormalizeRewrite:
+		return "rewrite"
+	case NormalizeReject:
+		return "reject"
+	case NormalizePassThrough:
+		return "pass-through"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the mode by name, as printed by String.
+func (m NormalizeMode) MarshalText() ([]byte, error) {
+	if m > NormalizePassThrough {
+		return nil, fmt.Errorf("unknown normalize mode: %d", m)
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText decodes a mode written by MarshalText.
+func (m *NormalizeMode) UnmarshalText(text []byte) error {
+	for mode := NormalizeDefault; mode <= NormalizePassThrough; mode++ {
+		if mode.String() == string(text) {
+			*m = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown normalize mode: %s", text)
+}
+
+// NormalizePolicy sets the mode of each path normalization. The zero value keeps the
+// historical behavior: redirect unclean paths and trailing-slash mismatches, and match
+// case-insensitively without touching the path.
+type NormalizePolicy struct {
+	// Clean covers "//", "." and ".." segments. Default: redirect.
+	Clean NormalizeMode `json:"clean"`
+	// TrailingSlash covers paths only registered with the other trailing slash, under
+	// StrictSlash (without StrictSlash both forms are served as is). Default: redirect.
+	TrailingSlash NormalizeMode `json:"trailingSlash"`
+	// Case covers paths with upper-case letters under IgnoreCase; the normal form
+	// lower-cases the static segments of the matched route and keeps param values as
+	// sent. Default: pass-through (matched case-insensitively).
+	Case NormalizeMode `json:"case"`
+	// CanonicalCase makes Case compare the path with the registered casing of the
+	// matched route instead of the lower-cased path: redirects and rewrites restore the
+	// case of static segments and keep param values as sent. Requires a Case mode.
+	CanonicalCase bool `json:"canonicalCase,omitempty"`
+}
+
+func (p NormalizePolicy) clean() NormalizeMode {
+	if p.Clean == NormalizeDefault {
+		return NormalizeRedirect
+	}
+	return p.Clean
+}
+
+func (p NormalizePolicy) trailingSlash() NormalizeMode {
+	if p.TrailingSlash == NormalizeDefault {
+		return NormalizeRedirect
+	}
+	return p.TrailingSlash
+}
+
+func (p NormalizePolicy) foldCase() NormalizeMode {
+	if p.Case == NormalizeDefault {
+		return NormalizePassThrough
+	}
+	return p.Case
+}
+
+// Normalization reports which normalizations were applied to a request.
+type Normalization uint8
+
+const (
+	// NormalizedClean: "//", "." or ".." segments were removed.
+	NormalizedClean Normalization = 1 << iota
+	// NormalizedTrailingSlash: the trailing slash was added or removed.
+	NormalizedTrailingSlash
+	// NormalizedCase: static segments were lower-cased, or given the registered casing.
+	NormalizedCase
+)
+
+// Normalized returns the normalizations the router rewrote into the request served by
+// w (see NormalizeRewrite), walking Unwrap like MatchedRoute. Zero means the path was
+// served as sent.
+func Normalized(w http.ResponseWriter) Normalization {
+	var n Normalization
+	for w != nil {
+		if prw, ok := w.(*paramRW); ok {
+			n |= prw.normalized
+		}
+		uw, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = uw.Unwrap()
+	}
+	return n
+}
+
+// rewriteRequest returns a shallow copy of req whose URL path is path (an escaped path
+// when raw), like http.StripPrefix does.
+func rewriteRequest(req *http.Request, path string, raw bool) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *req
+	r2.URL = new(neturl.URL)
+	*r2.URL = *req.URL
+	if !raw {
+		r2.URL.Path = path
+		r2.URL.RawPath = ""
+		return r2
+	}
+	r2.URL.RawPath = path
+	if decoded, err := neturl.PathUnescape(path); err == nil {
+		r2.URL.Path = decoded
+	} else {
+		r2.URL.Path = path
+		r2.URL.RawPath = ""
+	}
+	return r2
+}
+
+// markNormalized wraps w so that Normalized reports n. Release with unmarkNormalized.
+func markNormalized(w http.ResponseWriter, n Normalization) *paramRW {
+	prw := routeRWPool.Get().(*paramRW)
+	prw.ResponseWriter = w
+	prw.normalized = n
+	return prw
+}
+
+func unmarkNormalized(prw *paramRW) {
+	resetParamRW(prw)
+	routeRWPool.Put(prw)
+}
+
+// caseVariant is a route variant prepared at registration for the Case normalization.
+type caseVariant struct {
+	registered []string // spelling of each static segment; "" for params and mixed segments
+	lower      []string // registered, lower-cased
+	wildcard   bool     // a wildcard follows the segments
+}
+
+// newCaseVariants prepares the variants of a route for canonicalCasePath.
+func newCaseVariants(variants []routeVariant) []caseVariant {
+	out := make([]caseVariant, len(variants))
+	for i, v := range variants {
+		cv := &out[i]
+		for _, part := range v.parts {
+			if part != "" && part[0] == '*' {
+				cv.wildcard = true
+				break
+			}
+			if isDynamicPart(part) {
+				part = ""
+			}
+			cv.registered = append(cv.registered, part)
+			cv.lower = append(cv.lower, lowerASCII(part))
+		}
+	}
+	return out
+}
+
+// canonicalCasePath returns path with the static segments of the route spelled as
+// registered (lower-cased unless registered is set), and whether that differs from
+// path. Param, mixed and wildcard segments and the trailing slash are kept as sent.
+// Paths already in that form are checked in place, without allocating.
+func canonicalCasePath(casing []caseVariant, path string, registered bool) (string, bool) {
+	body := strings.TrimPrefix(path, "/")
+	slash := strings.HasSuffix(body, "/")
+	body = strings.TrimSuffix(body, "/")
+	for _, v := range casing {
+		want := v.lower
+		if registered {
+			want = v.registered
+		}
+		if diff, ok := v.align(want, body); ok {
+			if !diff {
+				return "", false
+			}
+			return v.rewrite(want, body, slash), true
+		}
+	}
+	return "", false
+}
+
+// align reports whether body has the segments of v, and whether a static one is
+// spelled other than want.
+func (v caseVariant) align(want []string, body string) (diff, ok bool) {
+	rest, more := body, body != ""
+	for _, w := range want {
+		if !more {
+			return false, false
+		}
+		var seg string
+		seg, rest, more = strings.Cut(rest, "/")
+		// A literal sent escaped (UseRawPath) does not fold to w and is kept as sent.
+		if w != "" && seg != w && strings.EqualFold(seg, w) {
+			diff = true
+		}
+	}
+	return diff, v.wildcard || !more
+}
+
+// rewrite returns body aligned by align with the static segments spelled as want.
+func (v caseVariant) rewrite(want []string, body string, slash bool) string {
+	var b strings.Builder
+	b.Grow(len(body) + 2)
+	rest := body
+	for _, w := range want {
+		var seg string
+		seg, rest, _ = strings.Cut(rest, "/")
+		if w != "" && strings.EqualFold(seg, w) {
+			seg = w
+		}
+		b.WriteByte('/')
+		b.WriteString(seg)
+	}
+	if rest != "" {
+		b.WriteByte('/')
+		b.WriteString(rest)
+	}
+	if b.Len() == 0 || slash {
+		b.WriteByte('/')
+	}
+	return b.String()
+}
+
+func respondBadPath(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusBadRequest)
+}
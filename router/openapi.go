@@ -0,0 +1,493 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIVersion is the OpenAPI version of generated documents.
+const OpenAPIVersion = "3.1.0"
+
+// OpenAPIInfo is the info object of a generated document.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIConfig configures OpenAPIJSON and OpenAPIYAML.
+type OpenAPIConfig struct {
+	Info OpenAPIInfo
+	// Host selects the host table to document (as in RouteInfo.Host); empty documents
+	// the default table.
+	Host string
+	// Servers lists base URLs of the API.
+	Servers []string
+	// Security is the default security requirement list of every operation.
+	Security []map[string][]string
+	// Components is a raw JSON components object (schemas, securitySchemes, ...)
+	// referenced by route schemas.
+	Components json.RawMessage
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components json.RawMessage                         `json:"components,omitempty"`
+	Security   []map[string][]string                   `json:"security,omitempty"`
+
+	// templates maps a path template with its param names blanked ("/a/{}") to the
+	// path documenting it, so that "/a/:id" and "/a/:name" share one path item.
+	templates map[string]string
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Deprecated  bool                       `json:"deprecated,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIBody               `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+	Security    *[]map[string][]string     `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string          `json:"name"`
+	In          string          `json:"in"`
+	Description string          `json:"description,omitempty"`
+	Required    bool            `json:"required"`
+	Schema      json.RawMessage `json:"schema"`
+}
+
+type openAPIBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema json.RawMessage `json:"schema"`
+}
+
+// OpenAPIJSON generates an OpenAPI 3.1 document from routes (as returned by Routes on
+// Router or FrozenRouter). Patterns are converted to path templates (":id" and "*path"
+// become "{id}" and "{path}"), constraints to parameter schemas, optional segments to
+// one path per variant and Any routes to every standard method without its own route.
+// Routes whose templates differ only in param names share the first route's path,
+// with the params of the others renamed to match.
+// Route metadata comes from RouteOptions.Doc; mounts and custom methods are skipped.
+//
+// Example:
+//
+//	_ = r.HandleWith(http.MethodGet, "/users/:id<int>", show, router.RouteOptions{
+//		Name: "getUser",
+//		Doc: &router.RouteDoc{
+//			Summary:   "Get a user",
+//			Responses: map[int]json.RawMessage{200: json.RawMessage(`{"$ref":"#/components/schemas/User"}`)},
+//		},
+//	})
+//	spec, err := router.OpenAPIJSON(r.Routes(), router.OpenAPIConfig{Info: router.OpenAPIInfo{Title: "API", Version: "1.0"}})
+func OpenAPIJSON(routes []RouteInfo, cfg OpenAPIConfig) ([]byte, error) {
+	doc, err := buildOpenAPI(routes, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// OpenAPIYAML is like OpenAPIJSON but emits YAML.
+func OpenAPIYAML(routes []RouteInfo, cfg OpenAPIConfig) ([]byte, error) {
+	doc, err := buildOpenAPI(routes, cfg)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return jsonToYAML(data)
+}
+
+func buildOpenAPI(routes []RouteInfo, cfg OpenAPIConfig) (*openAPIDocument, error) {
+	if cfg.Components != nil && !json.Valid(cfg.Components) {
+		return nil, fmt.Errorf("invalid components object")
+	}
+	doc := &openAPIDocument{
+		OpenAPI:    OpenAPIVersion,
+		Info:       cfg.Info,
+		Paths:      make(map[string]map[string]*openAPIOperation),
+		Components: cfg.Components,
+		Security:   cfg.Security,
+		templates:  make(map[string]string),
+	}
+	for _, u := range cfg.Servers {
+		doc.Servers = append(doc.Servers, openAPIServer{URL: u})
+	}
+
+	// Explicit methods first, so Any routes only fill the remaining methods.
+	var anyRoutes []RouteInfo
+	for _, info := range routes {
+		if info.Host != cfg.Host || info.Kind == RouteMount {
+			continue
+		}
+		if info.Method == MethodAny {
+			anyRoutes = append(anyRoutes, info)
+			continue
+		}
+		if err := addOpenAPIRoute(doc, info, []string{info.Method}); err != nil {
+			return nil, err
+		}
+	}
+	methods := make([]string, len(methodOrder))
+	for i, m := range methodOrder {
+		methods[i] = m.method
+	}
+	for _, info := range anyRoutes {
+		if err := addOpenAPIRoute(doc, info, methods); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func addOpenAPIRoute(doc *openAPIDocument, info RouteInfo, methods []string) error {
+	variants, err := expandOptional(info.Pattern)
+	if err != nil {
+		return err
+	}
+	for i, pattern := range variants {
+		path, params, err := openAPIPath(pattern)
+		if err != nil {
+			return err
+		}
+		// OpenAPI treats templates differing only in param names as the same path: the
+		// first route names the params, later ones are renamed by position.
+		shape, _ := openAPIShape(path)
+		if first, ok := doc.templates[shape]; ok && first != path {
+			_, names := openAPIShape(first)
+			for j := range params {
+				params[j].Name = names[j]
+			}
+			path = first
+		} else {
+			doc.templates[shape] = path
+		}
+		for _, method := range methods {
+			if !isOpenAPIMethod(method) {
+				continue
+			}
+			item := doc.Paths[path]
+			if item == nil {
+				item = make(map[string]*openAPIOperation)
+				doc.Paths[path] = item
+			}
+			key := strings.ToLower(method)
+			if _, exists := item[key]; exists {
+				continue
+			}
+			item[key] = newOpenAPIOperation(info, params, i == len(variants)-1)
+		}
+	}
+	return nil
+}
+
+func isOpenAPIMethod(method string) bool {
+	if _, ok := methodRank(method); ok {
+		return true
+	}
+	return method == http.MethodTrace
+}
+
+func newOpenAPIOperation(info RouteInfo, params []openAPIParameter, withID bool) *openAPIOperation {
+	op := &openAPIOperation{
+		Parameters: params,
+		Responses:  make(map[string]openAPIResponse),
+	}
+	d := info.Doc
+	if d == nil {
+		d = &RouteDoc{}
+	}
+	op.Summary = d.Summary
+	op.Description = d.Description
+	op.Tags = d.Tags
+	op.Deprecated = d.Deprecated
+	// Operation IDs must be unique: Any routes and the shorter optional variants get none.
+	if withID && info.Method != MethodAny {
+		op.OperationID = d.OperationID
+		if op.OperationID == "" {
+			op.OperationID = info.Name
+		}
+	}
+	if d.RequestBody != nil {
+		op.RequestBody = &openAPIBody{
+			Required: true,
+			Content:  openAPIContent(info.Consumes, d.RequestBody),
+		}
+	}
+	for code, schema := range d.Responses {
+		resp := openAPIResponse{Description: http.StatusText(code)}
+		if resp.Description == "" {
+			resp.Description = strconv.Itoa(code)
+		}
+		if schema != nil {
+			resp.Content = openAPIContent(info.Produces, schema)
+		}
+		op.Responses[strconv.Itoa(code)] = resp
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["default"] = openAPIResponse{Description: "Default response"}
+	}
+	if d.Security != nil {
+		security := d.Security
+		op.Security = &security
+	}
+	return op
+}
+
+// openAPIContent maps each media type (application/json by default) to schema.
+func openAPIContent(mediaTypes []string, schema json.RawMessage) map[string]openAPIMediaType {
+	if len(mediaTypes) == 0 {
+		mediaTypes = []string{"application/json"}
+	}
+	content := make(map[string]openAPIMediaType, len(mediaTypes))
+	for _, t := range mediaTypes {
+		content[t] = openAPIMediaType{Schema: schema}
+	}
+	return content
+}
+
+// openAPIPath converts a trie pattern to an OpenAPI path template with its path parameters.
+func openAPIPath(pattern string) (string, []openAPIParameter, error) {
+	var b strings.Builder
+	var params []openAPIParameter
+	parts := strings.Split(pattern, "/")
+	for i, part := range parts {
+		if i > 0 {
+			b.WriteByte('/')
+		}
+		if !isDynamicPart(part) {
+			b.WriteString(part)
+			continue
+		}
+		seg, err := parseSegment(part)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, tok := range seg.tokens {
+			if tok.name == "" {
+				b.WriteString(tok.literal)
+				continue
+			}
+			if tok.name == muxSubtree {
+				// A ServeMux subtree ("/static/") has no named param to document.
+				continue
+			}
+			b.WriteString("{" + tok.name + "}")
+			p := openAPIParameter{Name: tok.name, In: "path", Required: true, Schema: constraintSchema(tok.expr)}
+			if seg.kind == segmentWildcard {
+				p.Description = "Remaining path; may contain '/'."
+			}
+			params = append(params, p)
+		}
+	}
+	return b.String(), params, nil
+}
+
+// openAPIShape returns path with its "{name}" params blanked to "{}", and the names.
+func openAPIShape(path string) (string, []string) {
+	var b strings.Builder
+	var names []string
+	for {
+		open := strings.IndexByte(path, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(path[open:], '}')
+		if end < 0 {
+			break
+		}
+		b.WriteString(path[:open+1])
+		b.WriteByte('}')
+		names = append(names, path[open+1:open+end])
+		path = path[open+end+1:]
+	}
+	b.WriteString(path)
+	return b.String(), names
+}
+
+// constraintSchema returns the JSON Schema of a param constraint expression.
+func constraintSchema(expr string) json.RawMessage {
+	switch expr {
+	case "":
+		return json.RawMessage(`{"type":"string"}`)
+	case "int":
+		return json.RawMessage(`{"type":"integer"}`)
+	case "uint":
+		return json.RawMessage(`{"type":"integer","minimum":0}`)
+	case "uuid":
+		return json.RawMessage(`{"type":"string","format":"uuid"}`)
+	}
+	if values, ok := enumValues(expr); ok {
+		enum, _ := json.Marshal(values)
+		return json.RawMessage(`{"type":"string","enum":` + string(enum) + `}`)
+	}
+	pattern, _ := json.Marshal("^(?:" + expr + ")$")
+	return json.RawMessage(`{"type":"string","pattern":` + string(pattern) + `}`)
+}
+
+// jsonToYAML re-encodes a JSON document as block-style YAML, keeping key order.
+func jsonToYAML(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		return nil, fmt.Errorf("yaml: top-level value must be an object")
+	}
+	var b bytes.Buffer
+	if err := writeYAMLMapping(&b, dec, 0, false); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeYAMLMapping writes the members of an object whose '{' was consumed.
+// With inline, the first key continues the current line (after "- ").
+func writeYAMLMapping(b *bytes.Buffer, dec *json.Decoder, indent int, inline bool) error {
+	for first := true; dec.More(); first = false {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("yaml: unexpected key %v", tok)
+		}
+		if !first || !inline {
+			b.WriteString(strings.Repeat(" ", indent))
+		}
+		b.WriteString(yamlScalar(key))
+		b.WriteByte(':')
+		if err := writeYAMLValue(b, dec, indent+2); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token() // '}'
+	return err
+}
+
+// writeYAMLValue writes the next value after a "key:" or "-" prefix.
+func writeYAMLValue(b *bytes.Buffer, dec *json.Decoder, indent int) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	return writeYAMLToken(b, dec, tok, indent, false)
+}
+
+// writeYAMLToken writes the value starting at tok. With item, a mapping starts on the
+// "-" line of its sequence item.
+func writeYAMLToken(b *bytes.Buffer, dec *json.Decoder, tok json.Token, indent int, item bool) error {
+	switch tok {
+	case json.Delim('{'):
+		if !dec.More() {
+			b.WriteString(" {}\n")
+			_, err := dec.Token()
+			return err
+		}
+		if item {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte('\n')
+		}
+		return writeYAMLMapping(b, dec, indent, item)
+	case json.Delim('['):
+		if !dec.More() {
+			b.WriteString(" []\n")
+			_, err := dec.Token()
+			return err
+		}
+		b.WriteByte('\n')
+		for dec.More() {
+			b.WriteString(strings.Repeat(" ", indent))
+			b.WriteByte('-')
+			next, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if err := writeYAMLToken(b, dec, next, indent+2, true); err != nil {
+				return err
+			}
+		}
+		_, err := dec.Token() // ']'
+		return err
+	}
+	b.WriteByte(' ')
+	b.WriteString(yamlToken(tok))
+	b.WriteByte('\n')
+	return nil
+}
+
+func yamlToken(tok json.Token) string {
+	switch v := tok.(type) {
+	case string:
+		return yamlScalar(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return "null"
+	}
+}
+
+// yamlScalar returns s as a plain scalar when that is unambiguous, else double-quoted.
+func yamlScalar(s string) string {
+	if yamlPlain(s) {
+		return s
+	}
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+func yamlPlain(s string) bool {
+	if s == "" || s[len(s)-1] == ' ' {
+		return false
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "null", "yes", "no", "on", "off", "y", "n":
+		return false
+	}
+	c := s[0]
+	if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && c != '_' && c != '/' {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '_' || c == '-' || c == '.' || c == '/' || c == '{' || c == '}' || c == ' ':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrParamMissing is the Err of a ParamError for a param the matched route does not have.
+var ErrParamMissing = errors.New("missing param")
+
+// ParamError reports a route param that is missing or does not parse as the requested
+// type. Handlers can share one error path turning it into a 400:
+//
+//	id, err := router.ParamInt(w, "id")
+//	var pe *router.ParamError
+//	if errors.As(err, &pe) {
+//		http.Error(w, "bad "+pe.Name, http.StatusBadRequest)
+//		return
+//	}
+type ParamError struct {
+	Name  string // param name
+	Value string // value as matched; empty when missing
+	Type  string // requested type: "int", "int64", "uint", "bool", "uuid" or "time"
+	Err   error  // ErrParamMissing, or the parse error (e.g. strconv.ErrSyntax)
+}
+
+func (e *ParamError) Error() string {
+	if e.Err == ErrParamMissing {
+		return fmt.Sprintf("missing param '%s'", e.Name)
+	}
+	return fmt.Sprintf("invalid %s param '%s': %q: %v", e.Type, e.Name, e.Value, e.Err)
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// paramValue reads a param, reporting a missing one as a ParamError of type typ.
+func paramValue(w http.ResponseWriter, name, typ string) (string, error) {
+	v, ok := Param(w, name)
+	if !ok {
+		return "", &ParamError{Name: name, Type: typ, Err: ErrParamMissing}
+	}
+	return v, nil
+}
+
+// numError unwraps a strconv.NumError: ParamError already names the value.
+func numError(err error) error {
+	var ne *strconv.NumError
+	if errors.As(err, &ne) {
+		return ne.Err
+	}
+	return err
+}
+
+// ParamInt parses a route param as a base-10 int.
+func ParamInt(w http.ResponseWriter, name string) (int, error) {
+	v, err := paramValue(w, name, "int")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, &ParamError{Name: name, Value: v, Type: "int", Err: numError(err)}
+	}
+	return n, nil
+}
+
+// ParamInt64 parses a route param as a base-10 int64.
+func ParamInt64(w http.ResponseWriter, name string) (int64, error) {
+	v, err := paramValue(w, name, "int64")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, &ParamError{Name: name, Value: v, Type: "int64", Err: numError(err)}
+	}
+	return n, nil
+}
+
+// ParamUint parses a route param as a base-10 uint.
+func ParamUint(w http.ResponseWriter, name string) (uint, error) {
+	v, err := paramValue(w, name, "uint")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(v, 10, 0)
+	if err != nil {
+		return 0, &ParamError{Name: name, Value: v, Type: "uint", Err: numError(err)}
+	}
+	return uint(n), nil
+}
+
+// ParamBool parses a route param with strconv.ParseBool ("1", "t", "true", "0", "f",
+// "false", ...).
+func ParamBool(w http.ResponseWriter, name string) (bool, error) {
+	v, err := paramValue(w, name, "bool")
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, &ParamError{Name: name, Value: v, Type: "bool", Err: numError(err)}
+	}
+	return b, nil
+}
+
+// ParamUUID returns a route param holding a UUID in canonical text form
+// (8-4-4-4-12 hex digits, as accepted by the :name<uuid> constraint), lower-cased.
+func ParamUUID(w http.ResponseWriter, name string) (string, error) {
+	v, err := paramValue(w, name, "uuid")
+	if err != nil {
+		return "", err
+	}
+	if !isUUIDValue(v) {
+		return "", &ParamError{Name: name, Value: v, Type: "uuid", Err: strconv.ErrSyntax}
+	}
+	return lowerASCII(v), nil
+}
+
+// ParamTime parses a route param with time.Parse and layout (e.g. time.DateOnly).
+func ParamTime(w http.ResponseWriter, name, layout string) (time.Time, error) {
+	v, err := paramValue(w, name, "time")
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(layout, v)
+	if err != nil {
+		return time.Time{}, &ParamError{Name: name, Value: v, Type: "time", Err: err}
+	}
+	return t, nil
+}
+
+// ParamsFrom iterates over the params of the matched route in path order, followed by
+// a host param captured by a host pattern (see Router.Host).
+func ParamsFrom(w http.ResponseWriter) iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for w != nil {
+			if prw, ok := w.(*paramRW); ok && prw.params != nil {
+				for i, key := range prw.params.Keys {
+					if key == muxSubtree {
+						continue
+					}
+					if !yield(key, prw.params.Values[i]) {
+						return
+					}
+				}
+			}
+			uw, ok := w.(interface{ Unwrap() http.ResponseWriter })
+			if !ok {
+				return
+			}
+			w = uw.Unwrap()
+		}
+	}
+}
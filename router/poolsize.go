@@ -0,0 +1,61 @@
+package router
+
+import "sync/atomic"
+
+// Default capacities of pooled Params and pathSegments; routes with more params or
+// segments raise them (see poolSizes).
+const (
+	defaultParamsCap = 6
+	defaultPartsCap  = 20
+)
+
+// poolSizes tracks the largest route shape registered, so that Params and pathSegments
+// allocated by the pools already fit every route: a freshly allocated object (after a
+// GC emptied the pool) never grows while matching. Wildcard tails deeper than any
+// pattern still grow the pooled pathSegments once.
+type poolSizes struct {
+	params atomic.Int32 // most params of a route
+	depth  atomic.Int32 // most segments of a pattern
+	frames atomic.Bool  // preallocate the flat matcher stack (see MatcherFlat)
+	// treeFrames preallocates the stack of the tree matcher (see MatcherTree).
+	treeFrames atomic.Bool
+}
+
+// grow raises the sizes to fit a route with params params and depth segments.
+func (s *poolSizes) grow(params, depth int) {
+	raise(&s.params, params)
+	raise(&s.depth, depth)
+}
+
+func raise(v *atomic.Int32, n int) {
+	for {
+		cur := v.Load()
+		if int32(n) <= cur || v.CompareAndSwap(cur, int32(n)) {
+			return
+		}
+	}
+}
+
+func (s *poolSizes) newParams() interface{} {
+	n := max(int(s.params.Load()), defaultParamsCap)
+	return &Params{
+		Keys:   make([]string, 0, n), // preallocated capacity
+		Values: make([]string, 0, n),
+	}
+}
+
+func (s *poolSizes) newSegments() interface{} {
+	// [Optimization]: Pool struct to hold both parts and indices
+	n := max(int(s.depth.Load()), defaultPartsCap)
+	segs := &pathSegments{
+		parts:   make([]string, 0, n),
+		indices: make([]int, 0, n+1), // +1 for sentinel
+	}
+	if s.frames.Load() {
+		segs.frames = make([]flatFrame, flatMaxFrames)
+	}
+	if s.treeFrames.Load() {
+		segs.treeFrames = make([]treeFrame, treeMaxFrames)
+	}
+	return segs
+}
@@ -0,0 +1,221 @@
+package router
+
+import "fmt"
+
+// Remove unregisters the route registered with exactly this method and pattern
+// (param names and optional markers included). Empty trie nodes are pruned and the
+// route's name is released. A mount is removed with MethodAny and its prefix, as
+// listed by Routes. Safe to call while serving.
+func (r *Router) Remove(method, pattern string) error {
+	return r.remove("", method, pattern)
+}
+
+// Replace swaps the handler of an existing route, keeping its name and position.
+// The route's own, group and router middlewares are applied to the new handler, as
+// they were at registration. Mounts cannot be replaced; remove and mount them again.
+func (r *Router) Replace(method, pattern string, handler HandleFunc) error {
+	return r.replace("", method, pattern, handler)
+}
+
+// Remove unregisters a route registered through the group.
+func (g *Group) Remove(method, pattern string) error {
+	return g.router.remove(g.host, method, joinPaths(g.prefix, pattern))
+}
+
+// Replace swaps the handler of a route registered through the group. See Router.Replace.
+func (g *Group) Replace(method, pattern string, handler HandleFunc) error {
+	return g.router.replace(g.host, method, joinPaths(g.prefix, pattern), handler)
+}
+
+// lookupVariantsLocked returns the leaf of every variant, checking that each one belongs
+// to the route registered as pattern. Caller must hold r.mu.
+func (r *Router) lookupVariantsLocked(table *routeTable, method, pattern string, variants []routeVariant) ([]*node, error) {
+	root := table.roots[method]
+	if root == nil {
+		return nil, fmt.Errorf("route not found: %s %s", method, pattern)
+	}
+	leaves := make([]*node, len(variants))
+	for i, v := range variants {
+		leaf := root.find(v.matchParts, 0)
+		if leaf == nil || leaf.route == nil || leaf.route.Pattern != pattern {
+			return nil, fmt.Errorf("route not found: %s %s", method, pattern)
+		}
+		leaves[i] = leaf
+	}
+	return leaves, nil
+}
+
+func (r *Router) compileVariants(method, pattern string) ([]routeVariant, error) {
+	if !isValidMethod(method) {
+		return nil, fmt.Errorf("unsupported method: %s", method)
+	}
+	if cleaned := cleanPath(pattern); cleaned != pattern {
+		return nil, fmt.Errorf("non-canonical pattern: %s (clean: %s)", pattern, cleaned)
+	}
+	patterns, err := expandOptional(pattern)
+	if err != nil {
+		return nil, err
+	}
+	ignoreCase := r.lockIgnoreCase()
+	variants := make([]routeVariant, 0, len(patterns))
+	for _, p := range patterns {
+		v, err := r.compileVariant(p, ignoreCase)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, v)
+	}
+	return variants, nil
+}
+
+func (r *Router) remove(host, method, pattern string) error {
+	pattern, _, err := braceToPattern(pattern)
+	if err != nil {
+		return err
+	}
+	variants, err := r.compileVariants(method, pattern)
+	if err != nil {
+		return err
+	}
+	host = normalizeHostPattern(host)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	table := r.hostTableLocked(host)
+	if table == nil {
+		return fmt.Errorf("route not found: %s %s", method, pattern)
+	}
+	leaves, err := r.lookupVariantsLocked(table, method, pattern, variants)
+	if err != nil {
+		if method == MethodAny && r.removeMountLocked(table, host, pattern) {
+			return nil
+		}
+		return err
+	}
+	info := leaves[0].route
+	for _, v := range variants {
+		table.removeRoute(method, v)
+	}
+	if host != "" && table.isEmpty() {
+		delete(r.hosts, host)
+		r.hostPatterns = removeHostPattern(r.hostPatterns, host)
+	}
+	r.routesCount--
+	if info.Name != "" && r.names[info.Name] == info {
+		delete(r.names, info.Name)
+	}
+	r.publishLocked()
+	return nil
+}
+
+func (r *Router) replace(host, method, pattern string, handler HandleFunc) error {
+	if handler == nil {
+		return fmt.Errorf("nil handler for route: %s", pattern)
+	}
+	pattern, pathValues, err := braceToPattern(pattern)
+	if err != nil {
+		return err
+	}
+	variants, err := r.compileVariants(method, pattern)
+	if err != nil {
+		return err
+	}
+	host = normalizeHostPattern(host)
+	r.mu.RLock()
+	routerMws := make([]Middleware, len(r.middlewares))
+	copy(routerMws, r.middlewares)
+	var nego *negotiation
+	var routeMws, groupMws []Middleware
+	if table := r.hostTableLocked(host); table != nil {
+		if leaves, err := r.lookupVariantsLocked(table, method, pattern, variants); err == nil {
+			// The new handler keeps the media types, middlewares and ServeMux path values
+			// of the route.
+			info := leaves[0].route
+			nego, _ = r.negotiation(info.Consumes, info.Produces)
+			routeMws, groupMws = info.middlewares, info.groupMiddlewares
+			pathValues = pathValues || info.pathValues
+		}
+	}
+	r.mu.RUnlock()
+	if pathValues {
+		handler = withPathValues(handler)
+	}
+	if nego != nil {
+		handler = nego.wrap(handler)
+	}
+	if handler, err = applyMiddlewares(handler, routeMws); err != nil {
+		return err
+	}
+	if handler, err = applyMiddlewares(handler, groupMws); err != nil {
+		return err
+	}
+	if handler, err = applyMiddlewares(handler, routerMws); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	table := r.hostTableLocked(host)
+	if table == nil {
+		return fmt.Errorf("route not found: %s %s", method, pattern)
+	}
+	leaves, err := r.lookupVariantsLocked(table, method, pattern, variants)
+	if err != nil {
+		if method == MethodAny && findMountRoute(table.mounts, pattern) >= 0 {
+			return fmt.Errorf("cannot replace mount: %s (remove it and mount again)", pattern)
+		}
+		return err
+	}
+	if leaves[0].versions != nil {
+		return fmt.Errorf("cannot replace versioned route: %s %s", method, pattern)
+	}
+	if len(groupMws)+len(routerMws)+len(routeMws) > 0 {
+		handler = withRoute(leaves[0].route, handler)
+	}
+	for i, v := range variants {
+		leaves[i].handler = handler
+		if !v.hasParams {
+			table.static[method][v.matchPattern] = handler
+		}
+	}
+	r.publishLocked()
+	return nil
+}
+
+// removeMountLocked removes the mount listed as pattern. Caller must hold r.mu.
+func (r *Router) removeMountLocked(table *routeTable, host, pattern string) bool {
+	i := findMountRoute(table.mounts, pattern)
+	if i < 0 {
+		return false
+	}
+	table.mounts = append(table.mounts[:i:i], table.mounts[i+1:]...)
+	if host != "" && table.isEmpty() {
+		delete(r.hosts, host)
+		r.hostPatterns = removeHostPattern(r.hostPatterns, host)
+	}
+	r.routesCount--
+	r.publishLocked()
+	return true
+}
+
+// findMountRoute returns the index of the mount listed as pattern, or -1.
+func findMountRoute(mounts []mount, pattern string) int {
+	for i := range mounts {
+		if mounts[i].route.Pattern == pattern {
+			return i
+		}
+	}
+	return -1
+}
+
+// hostTableLocked returns the table for host without creating it. Caller must hold r.mu.
+func (r *Router) hostTableLocked(host string) *routeTable {
+	if host == "" {
+		return &r.table
+	}
+	return r.hosts[host]
+}
+
+func (t *routeTable) isEmpty() bool {
+	return len(t.roots) == 0 && len(t.mounts) == 0
+}
@@ -2,6 +2,7 @@ package router
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -58,10 +59,11 @@ func Param(w http.ResponseWriter, key string) (string, bool) {
 type routeTable struct {
 	roots       map[string]*node
 	static      map[string]map[string]HandleFunc
-	staticAllow map[string]string
+	staticAllow map[string]allowHeader
 	hasParams   map[string]bool
 	anyParams   bool
 	hasTrailing bool
+	mounts      []mount // longest prefix first
 }
 
 // Router holds the routing tree.
@@ -70,7 +72,9 @@ type routeTable struct {
 type Router struct {
 	mu    sync.RWMutex
 	table routeTable
-	hosts map[string]*routeTable // host -> routing table
+	hosts map[string]*routeTable // host (or host pattern) -> routing table
+	// hostPatterns indexes the wildcard/param keys of hosts, most specific first.
+	hostPatterns []hostPattern
 	// [Memory Optimization]
 	// We use sync.Pool to recycle objects. This dramatically reduces heap allocations.
 	// - paramPool: Recycles *Params objects (the map-like storage for :id, :user).
@@ -79,26 +83,61 @@ type Router struct {
 	paramPool sync.Pool // pool for Params (Zero Alloc Params)
 	partsPool sync.Pool // pool for pathSegments (Zero Alloc Split & Indices)
 	rwPool    sync.Pool // pool for paramRW wrappers (Zero Alloc Wrapper)
+	sizes     poolSizes // capacities of pooled Params and pathSegments
 
 	middlewares       []Middleware
+	names             map[string]*RouteInfo // route name -> route (reverse routing)
 	routesCount       int
 	ignoreCaseSet     bool
 	ignoreCaseEnabled bool
+	snapshot          snapshotState // copy-on-write serving (see EnableCopyOnWrite)
 	IgnoreCase        bool
 	StrictSlash       bool
-	UseRawPath        bool
-	NotFound          HandleFunc
-	MethodNotAllowed  HandleFunc
-	PanicHandler      func(http.ResponseWriter, *http.Request, any)
+	// StrictParamSlash extends StrictSlash to routes with params: /users/:id no longer
+	// serves /users/42/ but redirects it (or applies Normalize.TrailingSlash). Without
+	// it, param routes serve both forms.
+	StrictParamSlash bool
+	UseRawPath       bool
+	// UsePathValue also stores params on the request (req.PathValue) for code that
+	// only sees *http.Request. It costs allocations per param request; off by default.
+	UsePathValue     bool
+	NotFound         HandleFunc
+	MethodNotAllowed HandleFunc
+	PanicHandler     func(http.ResponseWriter, *http.Request, any)
+	// Versioning selects the handler of versioned routes. A route keeps the options
+	// in effect when its first version is registered, so set them before.
+	Versioning VersionOptions
+	// UnsupportedMediaType and NotAcceptable replace the empty 415 and 406 responses of
+	// routes declaring Consumes or Produces (NotAcceptable also serves versioned routes
+	// without VersionOptions.NotAcceptable). Captured when a route is registered.
+	UnsupportedMediaType HandleFunc
+	NotAcceptable        HandleFunc
+	// GlobalOPTIONS answers automatic OPTIONS requests (e.g. CORS preflight) instead of
+	// the empty 200. The Allow header is set before it runs.
+	GlobalOPTIONS HandleFunc
+	// DisableAutoOPTIONS stops answering OPTIONS for paths without an OPTIONS route;
+	// such requests get 405 like other unregistered methods, and Allow lists only
+	// registered methods.
+	DisableAutoOPTIONS bool
+	// SuppressHeadBody serves HEAD requests falling back to GET handlers through a
+	// writer that discards the body and sets Content-Length from the bytes written.
+	SuppressHeadBody bool
+	// Normalize chooses, per normalization (path cleaning, trailing slash, case), between
+	// redirecting, rewriting the request in place, rejecting it with 400 and leaving the
+	// path alone. The zero value keeps the redirects. See Normalized.
+	Normalize NormalizePolicy
 }
 
 // pathSegments holds path segments and original indices.
 // [Optimization]: supports O(1) wildcard slicing without allocations.
 type pathSegments struct {
-	path    string   // original path (for wildcard slicing)
-	match   string   // normalized path used for matching (e.g., lowercased)
-	parts   []string // split segments
-	indices []int    // start index of each segment in the normalized path
+	path    string      // original path (for wildcard slicing)
+	match   string      // normalized path used for matching (e.g., lowercased)
+	parts   []string    // split segments
+	indices []int       // start index of each segment in the normalized path
+	frames  []flatFrame // backtracking stack of the flat matcher (reused)
+	// treeFrames is the backtracking stack of frozenNode.search (reused).
+	treeFrames []treeFrame
 }
 
 // paramRW wraps http.ResponseWriter.
@@ -106,13 +145,42 @@ type pathSegments struct {
 type paramRW struct {
 	http.ResponseWriter
 	params *Params
+	route  *RouteInfo // matched route (see MatchedRoute)
+	// normalized marks a request whose path the router rewrote (see Normalized).
+	normalized Normalization
+	// pathValues reports that params were already copied to the request (UsePathValue).
+	pathValues bool
 }
 
+// Param looks up key in the route params, then in any wrapped paramRW
+// (e.g. a host param captured before path matching).
 func (w paramRW) Param(key string) (string, bool) {
-	if w.params == nil {
-		return "", false
+	if w.params != nil {
+		if v, ok := w.params.Get(key); ok {
+			return v, true
+		}
+	}
+	return Param(w.ResponseWriter, key)
+}
+
+// MatchedRoute returns the matched route, if this writer carries one.
+func (w paramRW) MatchedRoute() (RouteInfo, bool) {
+	if w.route == nil {
+		return RouteInfo{}, false
+	}
+	return *w.route, true
+}
+
+// RoutePattern returns the matched route pattern (e.g. "/users/:id"), or "" if unknown.
+// It lets packages that cannot import router label requests by route.
+func (w paramRW) RoutePattern() string {
+	if w.route != nil {
+		return w.route.Pattern
 	}
-	return w.params.Get(key)
+	if route, ok := MatchedRoute(w.ResponseWriter); ok {
+		return route.Pattern
+	}
+	return ""
 }
 
 func (w paramRW) Unwrap() http.ResponseWriter {
@@ -149,39 +217,27 @@ func (w paramRW) ReadFrom(r io.Reader) (int64, error) {
 
 // NewRouter creates a new router instance.
 func NewRouter() *Router {
-	return &Router{
+	r := &Router{
 		table: routeTable{
 			roots:       make(map[string]*node),
 			static:      make(map[string]map[string]HandleFunc),
-			staticAllow: make(map[string]string),
+			staticAllow: make(map[string]allowHeader),
 			hasParams:   make(map[string]bool),
 		},
 		hosts: make(map[string]*routeTable),
+		names: make(map[string]*RouteInfo),
 		// Best-practice default: normalize trailing slashes with redirects.
 		StrictSlash: true,
-		paramPool: sync.Pool{
-			New: func() interface{} {
-				return &Params{
-					Keys:   make([]string, 0, 6), // preallocated capacity
-					Values: make([]string, 0, 6),
-				}
-			},
-		},
-		partsPool: sync.Pool{
-			New: func() interface{} {
-				// [Optimization]: Pool struct to hold both parts and indices
-				return &pathSegments{
-					parts:   make([]string, 0, 20),
-					indices: make([]int, 0, 21), // +1 for sentinel
-				}
-			},
-		},
 		rwPool: sync.Pool{
 			New: func() interface{} {
 				return &paramRW{}
 			},
 		},
 	}
+	// Params and pathSegments are sized from the registered routes (see poolSizes).
+	r.paramPool.New = r.sizes.newParams
+	r.partsPool.New = r.sizes.newSegments
+	return r
 }
 
 // getParts splits the path with zero allocations and records indices.
@@ -336,24 +392,38 @@ func newRouteTable() *routeTable {
 	return &routeTable{
 		roots:       make(map[string]*node),
 		static:      make(map[string]map[string]HandleFunc),
-		staticAllow: make(map[string]string),
+		staticAllow: make(map[string]allowHeader),
 		hasParams:   make(map[string]bool),
 	}
 }
 
-func (r *Router) tableForHostLocked(host string) *routeTable {
+func (r *Router) tableForHostLocked(host string) (*routeTable, error) {
 	if host == "" {
-		return &r.table
+		return &r.table, nil
 	}
 	if r.hosts == nil {
 		r.hosts = make(map[string]*routeTable)
 	}
 	if t, ok := r.hosts[host]; ok {
-		return t
+		return t, nil
+	}
+	if !isHostPattern(host) && strings.IndexByte(host, '*') >= 0 {
+		return nil, fmt.Errorf("only the first host label can be a wildcard or param: %s", host)
+	}
+	if isHostPattern(host) {
+		p, err := parseHostPattern(host)
+		if err != nil {
+			return nil, err
+		}
+		patterns, err := insertHostPattern(r.hostPatterns, p)
+		if err != nil {
+			return nil, err
+		}
+		r.hostPatterns = patterns
 	}
 	t := newRouteTable()
 	r.hosts[host] = t
-	return t
+	return t, nil
 }
 
 func (r *Router) ignoreCaseActive() bool {
@@ -379,24 +449,111 @@ func (r *Router) lockIgnoreCase() bool {
 	return v
 }
 
+// setPathValues copies params onto the request so req.PathValue can read them.
+// The ServeMux subtree wildcard is not a param and is skipped.
+func setPathValues(req *http.Request, params *Params) {
+	for i, key := range params.Keys {
+		if key != muxSubtree {
+			req.SetPathValue(key, params.Values[i])
+		}
+	}
+}
+
 // resetParamRW resets paramRW for reuse.
 func resetParamRW(prw *paramRW) {
 	prw.ResponseWriter = nil
 	prw.params = nil
+	prw.route = nil
+	prw.normalized = 0
+	prw.pathValues = false
+}
+
+// RouteOptions configures a single route registered with HandleWith.
+type RouteOptions struct {
+	// Name identifies the route for reverse routing (see URL). Must be unique per router.
+	Name string
+	// Doc documents the route for the generated OpenAPI document (see OpenAPI). Optional.
+	Doc *RouteDoc
+	// Version registers the handler for one API version of the route (see Router.Version).
+	// Name and Doc belong to the route and are only accepted with its first version.
+	Version string
+	// Consumes lists the request media types the route accepts ("application/json",
+	// "image/*"). Requests with content of another type get 415 Unsupported Media Type.
+	Consumes []string
+	// Produces lists the media types the route responds with. Requests whose Accept
+	// header allows none of them get 406 Not Acceptable (see NegotiateContentType).
+	Produces []string
+	// Middlewares wrap this route only, inside the router and group middlewares. Like
+	// those, they are composed once at registration and kept by Replace.
+	Middlewares []Middleware
+
+	pathValues bool // registered with ServeMux syntax (see HandleFunc)
+}
+
+// RouteDoc is API documentation attached to a route. Schemas are raw JSON Schema,
+// copied into the OpenAPI document as is.
+type RouteDoc struct {
+	Summary     string   `json:"summary,omitempty"`
+	Description string   `json:"description,omitempty"`
+	OperationID string   `json:"operationId,omitempty"` // defaults to the route name
+	Tags        []string `json:"tags,omitempty"`
+	Deprecated  bool     `json:"deprecated,omitempty"`
+	// RequestBody is the JSON Schema of the request body, documented for each type of
+	// RouteOptions.Consumes (application/json by default).
+	RequestBody json.RawMessage `json:"requestBody,omitempty"`
+	// Responses maps status codes to the JSON Schema of their body (nil for no body),
+	// documented for each type of RouteOptions.Produces (application/json by default).
+	Responses map[int]json.RawMessage `json:"responses,omitempty"`
+	// Security lists alternative security requirements (scheme name -> scopes).
+	// Nil inherits the document default; an empty non-nil slice marks the route public.
+	Security []map[string][]string `json:"security"`
+}
+
+func (d *RouteDoc) validate() error {
+	if d.RequestBody != nil && !json.Valid(d.RequestBody) {
+		return fmt.Errorf("invalid request body schema")
+	}
+	for code, schema := range d.Responses {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid response status: %d", code)
+		}
+		if schema != nil && !json.Valid(schema) {
+			return fmt.Errorf("invalid response schema for status %d", code)
+		}
+	}
+	return nil
 }
 
 // Handle registers a route.
 func (r *Router) Handle(method, pattern string, handler HandleFunc) error {
-	return r.handle("", method, pattern, handler, nil)
+	return r.handle("", method, pattern, handler, nil, RouteOptions{})
+}
+
+// HandleWith registers a route with per-route options (e.g. a name for reverse routing).
+func (r *Router) HandleWith(method, pattern string, handler HandleFunc, opts RouteOptions) error {
+	return r.handle("", method, pattern, handler, nil, opts)
 }
 
-func (r *Router) handle(host, method, pattern string, handler HandleFunc, groupMws []Middleware) error {
+func (r *Router) handle(host, method, pattern string, handler HandleFunc, groupMws []Middleware, opts RouteOptions) error {
 	if handler == nil {
 		return fmt.Errorf("nil handler for route: %s", pattern)
 	}
 	if !isValidMethod(method) {
 		return fmt.Errorf("unsupported method: %s", method)
 	}
+	pattern, isMux, err := braceToPattern(pattern)
+	if err != nil {
+		return err
+	}
+	if isMux {
+		opts.pathValues = true
+	}
+	if opts.pathValues {
+		handler = withPathValues(handler)
+	}
+	if err := checkConstraintSlashes(pattern); err != nil {
+		return err
+	}
 	cleaned := cleanPath(pattern)
 	if cleaned != pattern {
 		return fmt.Errorf("non-canonical pattern: %s (clean: %s)", pattern, cleaned)
@@ -404,56 +561,41 @@ func (r *Router) handle(host, method, pattern string, handler HandleFunc, groupM
 	if len(cleaned) > MaxPathLength {
 		return fmt.Errorf("pattern too long: %s", pattern)
 	}
-
-	segs, ok := r.getParts(cleaned)
-	if !ok {
-		return fmt.Errorf("invalid pattern: %s", cleaned)
+	nego, err := r.negotiation(opts.Consumes, opts.Produces)
+	if err != nil {
+		return fmt.Errorf("%v for route: %s", err, pattern)
 	}
-	if len(segs.parts) > MaxDepth {
-		r.partsPool.Put(segs)
-		return fmt.Errorf("route too deep, possible DoS attack: %s", cleaned)
+	if nego != nil {
+		handler = nego.wrap(handler)
+	}
+	routeMws := append([]Middleware(nil), opts.Middlewares...)
+	if len(routeMws) > 0 {
+		composed, err := applyMiddlewares(handler, routeMws)
+		if err != nil {
+			return err
+		}
+		handler = composed
 	}
 
-	if err := validateParamNames(segs.parts, cleaned); err != nil {
-		r.partsPool.Put(segs)
+	patterns, err := expandOptional(cleaned)
+	if err != nil {
 		return err
 	}
-
 	ignoreCase := r.lockIgnoreCase()
-	matchPattern := cleaned
-	matchParts := segs.parts
-	if ignoreCase {
-		trailingSlash := len(cleaned) > 1 && cleaned[len(cleaned)-1] == '/'
-		matchParts = make([]string, len(segs.parts))
-		for i, part := range segs.parts {
-			if len(part) > 0 && (part[0] == ':' || part[0] == '*') {
-				matchParts[i] = part
-			} else {
-				matchParts[i] = lowerASCII(part)
-			}
-		}
-		if len(matchParts) == 0 {
-			matchPattern = "/"
-		} else {
-			matchPattern = "/" + strings.Join(matchParts, "/")
-			if trailingSlash {
-				matchPattern += "/"
-			}
-		}
-	}
-
-	hasParams := false
-	for _, part := range matchParts {
-		if len(part) > 0 && (part[0] == ':' || part[0] == '*') {
-			hasParams = true
-			break
+	variants := make([]routeVariant, 0, len(patterns))
+	for _, p := range patterns {
+		v, err := r.compileVariant(p, ignoreCase)
+		if err != nil {
+			return err
 		}
+		variants = append(variants, v)
 	}
+	// The longest variant carries every param name of the route.
+	full := variants[len(variants)-1]
 
 	if len(groupMws) > 0 {
 		composed, err := applyMiddlewares(handler, groupMws)
 		if err != nil {
-			r.partsPool.Put(segs)
 			return err
 		}
 		handler = composed
@@ -467,68 +609,234 @@ func (r *Router) handle(host, method, pattern string, handler HandleFunc, groupM
 	if len(routerMws) > 0 {
 		composed, err := applyMiddlewares(handler, routerMws)
 		if err != nil {
-			r.partsPool.Put(segs)
 			return err
 		}
 		handler = composed
 	}
 
-	// insert only needs parts
-	host = normalizeHost(host)
+	host = normalizeHostPattern(host)
+	if err := validateHostParam(host, full.parts); err != nil {
+		return err
+	}
+	info := newRouteInfo(method, host, cleaned, full.parts)
+	info.Name = opts.Name
+	info.pathValues = opts.pathValues
+	if nego != nil {
+		info.Consumes, info.Produces = nego.consumes, nego.produces
+	}
+	info.middlewares = routeMws
+	info.groupMiddlewares = append([]Middleware(nil), groupMws...)
+	info.casing = newCaseVariants(variants)
+	if opts.Doc != nil {
+		if err := opts.Doc.validate(); err != nil {
+			return fmt.Errorf("%v for route: %s", err, pattern)
+		}
+		doc := *opts.Doc
+		info.Doc = &doc
+	}
+	wrapRoute := len(groupMws)+len(routerMws)+len(routeMws) > 0
 
 	r.mu.Lock()
-	table := r.tableForHostLocked(host)
-	root, ok := table.roots[method]
-	if !ok {
-		root = &node{}
-		table.roots[method] = root
+	defer r.mu.Unlock()
+	if opts.Version != "" {
+		if table := r.hostTableLocked(host); table != nil {
+			if leaves, err := r.lookupVariantsLocked(table, method, cleaned, variants); err == nil {
+				return r.addVersionLocked(table, method, variants, leaves, handler, wrapRoute, opts)
+			}
+		}
 	}
-	err := root.insert(matchPattern, matchParts, 0, handler, hasParams)
-	if err == nil {
-		r.routesCount++
-		if len(matchPattern) > 1 && matchPattern[len(matchPattern)-1] == '/' {
-			table.hasTrailing = true
+	if opts.Name != "" {
+		if _, dup := r.names[opts.Name]; dup {
+			return fmt.Errorf("duplicate route name: %s", opts.Name)
 		}
-		if hasParams {
-			table.hasParams[method] = true
-			table.anyParams = true
-		} else {
-			m := table.static[method]
-			if m == nil {
-				m = make(map[string]HandleFunc, 8)
-				table.static[method] = m
-			}
-			m[matchPattern] = handler
-			if allow, ok := buildStaticAllowHeader(table.static, matchPattern); ok {
-				table.staticAllow[matchPattern] = allow
-			}
+	}
+	if wrapRoute {
+		handler = withRoute(info, handler)
+	}
+	var versions *versionSet
+	if opts.Version != "" {
+		vopts := r.Versioning
+		if vopts.NotAcceptable == nil {
+			vopts.NotAcceptable = r.NotAcceptable
 		}
+		if versions, err = newVersionSet(vopts).with(opts.Version, handler); err != nil {
+			return fmt.Errorf("%v for route: %s", err, pattern)
+		}
+		handler = versions.serve
 	}
-	r.mu.Unlock()
+	table, err := r.tableForHostLocked(host)
 	if err != nil {
-		r.partsPool.Put(segs)
 		return err
 	}
+	for i, v := range variants {
+		if err := table.insertRoute(method, v, handler, info); err != nil {
+			// Keep registration atomic: roll back variants inserted so far.
+			for _, done := range variants[:i] {
+				table.removeRoute(method, done)
+			}
+			return err
+		}
+	}
+	if versions != nil {
+		leaves, _ := r.lookupVariantsLocked(table, method, cleaned, variants)
+		for _, leaf := range leaves {
+			leaf.versions = versions
+		}
+	}
+	r.sizes.grow(len(info.Params), len(full.parts))
+	r.routesCount++
+	if opts.Name != "" {
+		if r.names == nil {
+			r.names = make(map[string]*RouteInfo)
+		}
+		r.names[opts.Name] = info
+	}
+	r.publishLocked()
+	return nil
+}
 
-	// Return segs to the pool after registration to keep pool semantics correct.
+// routeVariant is one concrete pattern of a route (optional segments expand to several).
+type routeVariant struct {
+	pattern      string   // canonical pattern with original casing
+	parts        []string // segments of pattern
+	matchPattern string   // pattern used as trie/static key (lowercased literals with IgnoreCase)
+	matchParts   []string // segments of matchPattern
+	hasParams    bool
+}
+
+func (r *Router) compileVariant(pattern string, ignoreCase bool) (routeVariant, error) {
+	segs, ok := r.getParts(pattern)
+	if !ok {
+		return routeVariant{}, fmt.Errorf("invalid pattern: %s", pattern)
+	}
+	parts := append([]string(nil), segs.parts...)
+	// Return segs to the pool right away: parts is a private copy.
 	r.partsPool.Put(segs)
+	if len(parts) > MaxDepth {
+		return routeVariant{}, fmt.Errorf("route too deep, possible DoS attack: %s", pattern)
+	}
+	if err := validateParamNames(parts, pattern); err != nil {
+		return routeVariant{}, err
+	}
+
+	v := routeVariant{pattern: pattern, parts: parts, matchPattern: pattern, matchParts: parts}
+	if ignoreCase {
+		trailingSlash := len(pattern) > 1 && pattern[len(pattern)-1] == '/'
+		v.matchParts = make([]string, len(parts))
+		for i, part := range parts {
+			v.matchParts[i] = lowerSegmentLiterals(part)
+		}
+		if len(v.matchParts) == 0 {
+			v.matchPattern = "/"
+		} else {
+			v.matchPattern = "/" + strings.Join(v.matchParts, "/")
+			if trailingSlash {
+				v.matchPattern += "/"
+			}
+		}
+	}
+	for _, part := range v.matchParts {
+		if isDynamicPart(part) {
+			v.hasParams = true
+			break
+		}
+	}
+	return v, nil
+}
+
+// insertRoute inserts a variant into the trie and keeps the fast-path indexes in sync.
+func (t *routeTable) insertRoute(method string, v routeVariant, handler HandleFunc, info *RouteInfo) error {
+	root, ok := t.roots[method]
+	if !ok {
+		root = &node{}
+		t.roots[method] = root
+	}
+	if err := root.insert(v.matchPattern, v.matchParts, 0, handler, info, v.hasParams); err != nil {
+		if root.isEmpty() {
+			delete(t.roots, method)
+		}
+		return err
+	}
+	if len(v.matchPattern) > 1 && v.matchPattern[len(v.matchPattern)-1] == '/' {
+		t.hasTrailing = true
+	}
+	if v.hasParams {
+		t.hasParams[method] = true
+		t.anyParams = true
+		return nil
+	}
+	m := t.static[method]
+	if m == nil {
+		m = make(map[string]HandleFunc, 8)
+		t.static[method] = m
+	}
+	m[v.matchPattern] = handler
+	if allow, ok := buildStaticAllowHeader(t.static, v.matchPattern); ok {
+		t.staticAllow[v.matchPattern] = allow
+	}
 	return nil
 }
 
+// removeRoute removes a variant from the trie, pruning empty nodes and
+// recomputing the fast-path indexes (staticAllow, hasParams, anyParams, hasTrailing).
+func (t *routeTable) removeRoute(method string, v routeVariant) bool {
+	root := t.roots[method]
+	if root == nil || !root.remove(v.matchParts, 0) {
+		return false
+	}
+	if root.isEmpty() {
+		delete(t.roots, method)
+		root = nil
+	}
+	if !v.hasParams {
+		if m := t.static[method]; m != nil {
+			delete(m, v.matchPattern)
+			if len(m) == 0 {
+				delete(t.static, method)
+			}
+		}
+		if allow, ok := buildStaticAllowHeader(t.static, v.matchPattern); ok {
+			t.staticAllow[v.matchPattern] = allow
+		} else {
+			delete(t.staticAllow, v.matchPattern)
+		}
+	}
+	if root.anyLeaf(func(n *node) bool { return n.hasParams }) {
+		t.hasParams[method] = true
+	} else {
+		delete(t.hasParams, method)
+	}
+	t.anyParams = false
+	for _, has := range t.hasParams {
+		if has {
+			t.anyParams = true
+			break
+		}
+	}
+	t.hasTrailing = false
+	for _, r := range t.roots {
+		if r.anyLeaf(func(n *node) bool { return len(n.pattern) > 1 && n.pattern[len(n.pattern)-1] == '/' }) {
+			t.hasTrailing = true
+			break
+		}
+	}
+	return true
+}
+
 func validateParamNames(parts []string, pattern string) error {
 	var seen map[string]struct{}
 	for i, part := range parts {
-		if len(part) == 0 {
+		if !isDynamicPart(part) {
 			continue
 		}
-		if part[0] == '*' && i != len(parts)-1 {
+		seg, err := parseSegment(part)
+		if err != nil {
+			return fmt.Errorf("%v in path: %s", err, pattern)
+		}
+		if seg.kind == segmentWildcard && i != len(parts)-1 {
 			return fmt.Errorf("wildcard * must be at the end of path: %s", pattern)
 		}
-		if part[0] == ':' || part[0] == '*' {
-			name := part[1:]
-			if name == "" {
-				return fmt.Errorf("parameter must have a name (e.g., :id) in path: %s", pattern)
-			}
+		for _, name := range seg.params() {
 			if seen == nil {
 				seen = make(map[string]struct{}, 4)
 			}
@@ -541,6 +849,15 @@ func validateParamNames(parts []string, pattern string) error {
 	return nil
 }
 
+// MethodAny registers a route matching every method, including custom ones.
+// Routes registered for the request method (and GET for HEAD) take priority.
+const MethodAny = "*"
+
+// Any registers a route matching every method. It is Handle(MethodAny, ...).
+func (r *Router) Any(pattern string, handler HandleFunc) error {
+	return r.Handle(MethodAny, pattern, handler)
+}
+
 func (r *Router) GET(pattern string, handler HandleFunc) error {
 	return r.Handle(http.MethodGet, pattern, handler)
 }
@@ -571,6 +888,12 @@ func (r *Router) OPTIONS(pattern string, handler HandleFunc) error {
 
 // ServeHTTP implements http.Handler.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// Copy-on-write mode: serve the published snapshot lock-free.
+	if snap := r.snapshot.current.Load(); snap != nil {
+		snap.ServeHTTP(w, req)
+		return
+	}
+
 	if r.PanicHandler != nil {
 		defer func() {
 			if rec := recover(); rec != nil {
@@ -579,49 +902,54 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		}()
 	}
 
-	ctx, ok := prepareRouteContext(w, req, r.UseRawPath, r.ignoreCaseActive())
+	ctx, req, ok := prepareRouteContext(w, req, r.UseRawPath, r.ignoreCaseActive(), r.Normalize)
 	if !ok {
 		return // Already responded (redirect or error)
 	}
+	if ctx.normalized != 0 {
+		nw := markNormalized(w, ctx.normalized)
+		r.serveRequest(nw, req, ctx)
+		unmarkNormalized(nw)
+		return
+	}
+	r.serveRequest(w, req, ctx)
+}
 
+// serveRequest routes a prepared request: host table, default table, then NotFound.
+func (r *Router) serveRequest(w http.ResponseWriter, req *http.Request, ctx routeContext) {
 	host := normalizeHost(req.Host)
 
 	var hostTable *routeTable
-	hasHost := false
+	var hostParam, hostLabel string
 	r.mu.RLock()
 	if host != "" {
-		if t, ok := r.hosts[host]; ok {
+		if t, ok := r.hosts[host]; ok && !isHostPattern(host) {
 			hostTable = t
-			hasHost = true
+		} else if p, label := matchHostPattern(r.hostPatterns, host); p != nil {
+			hostTable = r.hosts[p.pattern]
+			hostParam, hostLabel = p.param, label
 		}
 	}
-	if hostTable == nil {
-		hostTable = &r.table
-	}
 	defaultTable := &r.table
 	r.mu.RUnlock()
 
 	// Try host-specific table first
-	if hasHost {
-		if r.serveInTable(w, req, ctx.method, ctx.matchPath, ctx.paramPath, hostTable) {
-			return
-		}
-		if r.tryAlternateSlashInTable(w, req, ctx, hostTable) {
-			return
-		}
-		if r.handleMethodNotAllowedInTable(w, req, ctx, hostTable) {
+	if hostTable != nil {
+		if hostParam == "" {
+			if r.serveTable(w, req, ctx, hostTable) {
+				return
+			}
+		} else if r.serveTableWithHostParam(w, req, ctx, hostTable, hostParam, hostLabel) {
 			return
 		}
 	}
 
 	// Try default table
-	if r.serveInTable(w, req, ctx.method, ctx.matchPath, ctx.paramPath, defaultTable) {
-		return
-	}
-	if r.tryAlternateSlashInTable(w, req, ctx, defaultTable) {
+	if r.serveTable(w, req, ctx, defaultTable) {
 		return
 	}
-	if r.handleMethodNotAllowedInTable(w, req, ctx, defaultTable) {
+	if ctx.redirect {
+		ctx.redirectFn(w, req, ctx.paramPath)
 		return
 	}
 
@@ -632,6 +960,51 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	http.NotFound(w, req)
 }
 
+// serveTable routes the request within one table: exact match, alternate slash,
+// mounts, then 405.
+func (r *Router) serveTable(w http.ResponseWriter, req *http.Request, ctx routeContext, table *routeTable) bool {
+	if ctx.canonicalCase {
+		info := r.routeInTable(ctx.method, ctx.matchPath, ctx.paramPath, table)
+		if info == nil && ctx.redirect {
+			return false // serveRequest redirects once no table has the route
+		}
+		if info != nil {
+			if canonical, ok := canonicalCasePath(info.casing, ctx.paramPath, r.Normalize.CanonicalCase); ok {
+				return r.serveCanonicalInTable(w, req, ctx, table, canonical)
+			}
+			if ctx.redirect {
+				ctx.redirectFn(w, req, ctx.paramPath)
+				return true
+			}
+		}
+	}
+	return r.serveInTable(w, req, ctx.method, ctx.matchPath, ctx.paramPath, table) ||
+		r.tryAlternateSlashInTable(w, req, ctx, table) ||
+		r.serveMountInTable(w, req, ctx, table) ||
+		r.handleMethodNotAllowedInTable(w, req, ctx, table)
+}
+
+// serveTableWithHostParam is serveTable with the captured host label exposed via Param.
+func (r *Router) serveTableWithHostParam(w http.ResponseWriter, req *http.Request, ctx routeContext, table *routeTable, name, label string) bool {
+	params := r.paramPool.Get().(*Params)
+	params.Reset()
+	params.Add(name, label)
+	prw := r.rwPool.Get().(*paramRW)
+	prw.ResponseWriter = w
+	prw.params = params
+	if r.UsePathValue {
+		setPathValues(req, params)
+		prw.pathValues = true
+	}
+
+	served := r.serveTable(prw, req, ctx, table)
+
+	resetParamRW(prw)
+	r.rwPool.Put(prw)
+	r.paramPool.Put(params)
+	return served
+}
+
 func (r *Router) tryAlternateSlashInTable(w http.ResponseWriter, req *http.Request, ctx routeContext, table *routeTable) bool {
 	// Fast skip for the common "no trailing slash route exists" case.
 	if len(ctx.matchPath) > 1 && ctx.matchPath[len(ctx.matchPath)-1] != '/' && !table.hasTrailing {
@@ -642,12 +1015,8 @@ func (r *Router) tryAlternateSlashInTable(w http.ResponseWriter, req *http.Reque
 		return false
 	}
 	if r.StrictSlash {
-		if _, ok := r.allowedMethodsInTable(altMatch, table); ok {
-			altRedirect, ok := alternatePath(ctx.paramPath)
-			if ok && altRedirect != "" {
-				ctx.redirectFn(w, req, altRedirect)
-				return true
-			}
+		if allow, ok := r.allowedMethodsInTable(altMatch, table, true); ok {
+			return r.normalizeSlashInTable(w, req, ctx, table, altMatch, allow)
 		}
 		return false
 	}
@@ -655,17 +1024,110 @@ func (r *Router) tryAlternateSlashInTable(w http.ResponseWriter, req *http.Reque
 	return r.serveInTable(w, req, ctx.method, altMatch, altParam, table)
 }
 
+// routeInTable returns the route serving method at matchPath, falling back like
+// serveInTable (HEAD to GET, then Any), or nil.
+func (r *Router) routeInTable(method, matchPath, rawPath string, table *routeTable) *RouteInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	segs, ok := r.getPartsWithRaw(matchPath, rawPath)
+	if !ok {
+		return nil
+	}
+	defer r.partsPool.Put(segs)
+	if len(segs.parts) > MaxDepth {
+		return nil
+	}
+	for i, m := range [...]string{method, http.MethodGet, MethodAny} {
+		if i == 1 && method != http.MethodHead {
+			continue
+		}
+		root := table.roots[m]
+		if root == nil {
+			continue
+		}
+		if n := root.search(segs, 0, nil); n != nil && n.handler != nil && (!r.StrictParamSlash || !r.StrictSlash || slashMatches(n.pattern, n.part, matchPath)) {
+			return n.route
+		}
+	}
+	return nil
+}
+
+// serveCanonicalInTable applies Normalize.Case to a path matching a route in another
+// casing than its normal form (see NormalizePolicy.Case).
+func (r *Router) serveCanonicalInTable(w http.ResponseWriter, req *http.Request, ctx routeContext, table *routeTable, canonical string) bool {
+	switch r.Normalize.foldCase() {
+	case NormalizeReject:
+		respondBadPath(w)
+		return true
+	case NormalizeRewrite:
+		if ctx.redirect {
+			break // the Clean redirect carries the casing
+		}
+		req = rewriteRequest(req, canonical, ctx.useRaw)
+		ctx.paramPath = canonical
+		ctx.canonicalCase = false
+		nw := markNormalized(w, NormalizedCase)
+		served := r.serveTable(nw, req, ctx, table)
+		unmarkNormalized(nw)
+		return served
+	}
+	ctx.redirectFn(w, req, canonical)
+	return true
+}
+
+// normalizeSlashInTable applies Normalize.TrailingSlash to a request only registered
+// with the other trailing slash (altMatch, allowing allow).
+func (r *Router) normalizeSlashInTable(w http.ResponseWriter, req *http.Request, ctx routeContext, table *routeTable, altMatch, allow string) bool {
+	altParam, ok := alternatePath(ctx.paramPath)
+	if !ok || altParam == "" {
+		return false
+	}
+	switch r.Normalize.trailingSlash() {
+	case NormalizePassThrough:
+		return false
+	case NormalizeReject:
+		respondBadPath(w)
+		return true
+	case NormalizeRewrite:
+		req = rewriteRequest(req, altParam, ctx.useRaw)
+		nw := markNormalized(w, NormalizedTrailingSlash)
+		if !r.serveInTable(nw, req, ctx.method, altMatch, altParam, table) {
+			respondMethodNotAllowed(nw, req, allow, r.MethodNotAllowed, r.GlobalOPTIONS, !r.DisableAutoOPTIONS)
+		}
+		unmarkNormalized(nw)
+		return true
+	default:
+		ctx.redirectFn(w, req, altParam)
+		return true
+	}
+}
+
+func (r *Router) serveMountInTable(w http.ResponseWriter, req *http.Request, ctx routeContext, table *routeTable) bool {
+	r.mu.RLock()
+	if len(table.mounts) == 0 {
+		r.mu.RUnlock()
+		return false
+	}
+	m := findMount(table.mounts, ctx.matchPath)
+	r.mu.RUnlock()
+	if m == nil {
+		return false
+	}
+	serveMount(w, req, m)
+	return true
+}
+
 func (r *Router) handleMethodNotAllowedInTable(w http.ResponseWriter, req *http.Request, ctx routeContext, table *routeTable) bool {
-	if allow, ok := r.allowedMethodsInTable(ctx.matchPath, table); ok {
-		return respondMethodNotAllowed(w, req, allow, r.MethodNotAllowed)
+	if allow, ok := r.allowedMethodsInTable(ctx.matchPath, table, r.StrictSlash && r.StrictParamSlash); ok {
+		return respondMethodNotAllowed(w, req, allow, r.MethodNotAllowed, r.GlobalOPTIONS, !r.DisableAutoOPTIONS)
 	}
 	if !r.StrictSlash {
 		if len(ctx.matchPath) > 1 && ctx.matchPath[len(ctx.matchPath)-1] != '/' && !table.hasTrailing {
 			return false
 		}
 		if altMatch, ok := alternatePath(ctx.matchPath); ok {
-			if allow, ok := r.allowedMethodsInTable(altMatch, table); ok {
-				return respondMethodNotAllowed(w, req, allow, r.MethodNotAllowed)
+			if allow, ok := r.allowedMethodsInTable(altMatch, table, false); ok {
+				return respondMethodNotAllowed(w, req, allow, r.MethodNotAllowed, r.GlobalOPTIONS, !r.DisableAutoOPTIONS)
 			}
 		}
 	}
@@ -673,13 +1135,25 @@ func (r *Router) handleMethodNotAllowedInTable(w http.ResponseWriter, req *http.
 }
 
 func (r *Router) serveInTable(w http.ResponseWriter, req *http.Request, method, matchPath, rawPath string, table *routeTable) bool {
-	if method == http.MethodHead {
-		if r.serveMethodInTable(w, req, http.MethodHead, matchPath, rawPath, table) {
-			return true
-		}
+	if r.serveMethodInTable(w, req, method, matchPath, rawPath, table) {
+		return true
+	}
+	if method == http.MethodHead && r.serveHeadAsGet(w, req, matchPath, rawPath, table) {
+		return true
+	}
+	return method != MethodAny && r.serveMethodInTable(w, req, MethodAny, matchPath, rawPath, table)
+}
+
+// serveHeadAsGet serves HEAD with the GET route, discarding the body when
+// SuppressHeadBody is set.
+func (r *Router) serveHeadAsGet(w http.ResponseWriter, req *http.Request, matchPath, rawPath string, table *routeTable) (served bool) {
+	if !r.SuppressHeadBody {
 		return r.serveMethodInTable(w, req, http.MethodGet, matchPath, rawPath, table)
 	}
-	return r.serveMethodInTable(w, req, method, matchPath, rawPath, table)
+	hw := acquireHeadRW(w)
+	defer func() { releaseHeadRW(hw, served) }()
+	served = r.serveMethodInTable(hw, req, http.MethodGet, matchPath, rawPath, table)
+	return served
 }
 
 func (r *Router) serveMethodInTable(w http.ResponseWriter, req *http.Request, method, matchPath, rawPath string, table *routeTable) bool {
@@ -718,9 +1192,10 @@ func (r *Router) serveMethodInTable(w http.ResponseWriter, req *http.Request, me
 	}
 
 	node := root.search(segs, 0, nil)
-	if node != nil && node.handler != nil {
+	if node != nil && node.handler != nil && (!r.StrictParamSlash || !r.StrictSlash || slashMatches(node.pattern, node.part, matchPath)) {
 		handler := node.handler
 		hasParams := node.hasParams
+		route := node.route
 		if !hasParams {
 			r.mu.RUnlock()
 			handler(w, req)
@@ -736,6 +1211,11 @@ func (r *Router) serveMethodInTable(w http.ResponseWriter, req *http.Request, me
 		prw := r.rwPool.Get().(*paramRW)
 		prw.ResponseWriter = w
 		prw.params = params
+		prw.route = route
+		if r.UsePathValue {
+			setPathValues(req, params)
+			prw.pathValues = true
+		}
 
 		handler(prw, req)
 
@@ -751,12 +1231,12 @@ func (r *Router) serveMethodInTable(w http.ResponseWriter, req *http.Request, me
 	return false
 }
 
-func (r *Router) allowedMethodsInTable(matchPath string, table *routeTable) (string, bool) {
+func (r *Router) allowedMethodsInTable(matchPath string, table *routeTable, exactSlash bool) (string, bool) {
 	r.mu.RLock()
 	if !table.anyParams {
 		if allow, ok := table.staticAllow[matchPath]; ok {
 			r.mu.RUnlock()
-			return allow, true
+			return allow.value(!r.DisableAutoOPTIONS), true
 		}
 		r.mu.RUnlock()
 		return "", false
@@ -798,7 +1278,7 @@ func (r *Router) allowedMethodsInTable(matchPath string, table *routeTable) (str
 				return "", false
 			}
 		}
-		if root.search(segs, 0, nil) != nil {
+		if n := root.search(segs, 0, nil); n != nil && (!exactSlash || slashMatches(n.pattern, n.part, matchPath)) {
 			bits, custom = addAllowedMethod(method, bits, custom)
 		}
 	}
@@ -811,12 +1291,7 @@ func (r *Router) allowedMethodsInTable(matchPath string, table *routeTable) (str
 	if bits == 0 && len(custom) == 0 {
 		return "", false
 	}
-	if bits&allowMethodGet != 0 {
-		bits |= allowMethodHead
-	}
-	bits |= allowMethodOptions
-
-	return buildAllowHeader(bits, custom), true
+	return allowValue(bits, custom, !r.DisableAutoOPTIONS), true
 }
 
 const (
@@ -854,14 +1329,34 @@ var standardMethodBits = map[string]uint8{
 	http.MethodOptions: allowMethodOptions,
 }
 
+// allowMethodAll is advertised for MethodAny routes.
+const allowMethodAll = allowMethodGet | allowMethodHead | allowMethodPost | allowMethodPut |
+	allowMethodPatch | allowMethodDelete | allowMethodOptions
+
 func addAllowedMethod(method string, bits uint8, custom []string) (uint8, []string) {
+	if method == MethodAny {
+		return bits | allowMethodAll, custom
+	}
 	if bit, ok := standardMethodBits[method]; ok {
 		return bits | bit, custom
 	}
 	return bits, appendCustomMethod(custom, method)
 }
 
-func buildStaticAllowHeader(static map[string]map[string]HandleFunc, path string) (string, bool) {
+// allowHeader is a cached Allow value with and without the automatic OPTIONS.
+type allowHeader struct {
+	auto  string // OPTIONS is answered automatically
+	plain string // only registered methods (DisableAutoOPTIONS)
+}
+
+func (a allowHeader) value(autoOptions bool) string {
+	if autoOptions {
+		return a.auto
+	}
+	return a.plain
+}
+
+func buildStaticAllowHeader(static map[string]map[string]HandleFunc, path string) (allowHeader, bool) {
 	var bits uint8
 	var custom []string
 	for method, routes := range static {
@@ -873,13 +1368,21 @@ func buildStaticAllowHeader(static map[string]map[string]HandleFunc, path string
 		}
 	}
 	if bits == 0 && len(custom) == 0 {
-		return "", false
+		return allowHeader{}, false
 	}
+	return allowHeader{auto: allowValue(bits, custom, true), plain: allowValue(bits, custom, false)}, true
+}
+
+// allowValue builds the Allow value of the registered methods. GET implies HEAD, and
+// OPTIONS is added when the router answers it automatically.
+func allowValue(bits uint8, custom []string, autoOptions bool) string {
 	if bits&allowMethodGet != 0 {
 		bits |= allowMethodHead
 	}
-	bits |= allowMethodOptions
-	return buildAllowHeader(bits, custom), true
+	if autoOptions {
+		bits |= allowMethodOptions
+	}
+	return buildAllowHeader(bits, custom)
 }
 
 func appendCustomMethod(custom []string, method string) []string {
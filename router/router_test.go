@@ -2,15 +2,24 @@ package router
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/willunylabs/wand/logger"
 	"github.com/willunylabs/wand/middleware"
@@ -1427,6 +1436,2693 @@ func TestRouter_Param_WithMiddlewareWrapper(t *testing.T) {
 	}
 }
 
+func TestRouter_Routes(t *testing.T) {
+	r := NewRouter()
+	r.IgnoreCase = true
+	h := func(w http.ResponseWriter, req *http.Request) {}
+	mustGET(t, r, "/users/:id", h)
+	mustGET(t, r, "/Static/*filepath", h)
+	if err := r.POST("/users", h); err != nil {
+		t.Fatalf("register post failed: %v", err)
+	}
+	mustGET(t, r, "/users", h)
+	if err := r.Handle("PURGE", "/users", h); err != nil {
+		t.Fatalf("register purge failed: %v", err)
+	}
+	if err := r.Host("api.example.com").GET("/v1/items/:id/*rest", h); err != nil {
+		t.Fatalf("register host route failed: %v", err)
+	}
+
+	want := []RouteInfo{
+		{Method: http.MethodGet, Pattern: "/Static/*filepath", Params: []string{"filepath"}, Kind: RouteWildcard},
+		{Method: http.MethodGet, Pattern: "/users", Kind: RouteStatic},
+		{Method: http.MethodPost, Pattern: "/users", Kind: RouteStatic},
+		{Method: "PURGE", Pattern: "/users", Kind: RouteStatic},
+		{Method: http.MethodGet, Pattern: "/users/:id", Params: []string{"id"}, Kind: RouteParam},
+		{Method: http.MethodGet, Host: "api.example.com", Pattern: "/v1/items/:id/*rest", Params: []string{"id", "rest"}, Kind: RouteWildcard},
+	}
+	check := func(name string, got []RouteInfo) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("%s: expected %d routes, got %d: %+v", name, len(want), len(got), got)
+		}
+		for i := range want {
+			g, w := got[i], want[i]
+			if g.Method != w.Method || g.Host != w.Host || g.Pattern != w.Pattern || g.Kind != w.Kind || strings.Join(g.Params, ",") != strings.Join(w.Params, ",") {
+				t.Fatalf("%s: route %d mismatch: got %+v want %+v", name, i, g, w)
+			}
+		}
+	}
+	check("router", r.Routes())
+	check("frozen", mustFreeze(t, r).Routes())
+
+	// Returned values are copies.
+	routes := r.Routes()
+	routes[0].Params[0] = "mutated"
+	if got := r.Routes()[0].Params[0]; got != "filepath" {
+		t.Fatalf("expected routes to be copied, got %q", got)
+	}
+}
+
+func TestRouter_Walk_StopsOnError(t *testing.T) {
+	r := NewRouter()
+	h := func(w http.ResponseWriter, req *http.Request) {}
+	mustGET(t, r, "/a", h)
+	mustGET(t, r, "/b", h)
+
+	stop := fmt.Errorf("stop")
+	var seen []string
+	err := r.Walk(func(info RouteInfo) error {
+		seen = append(seen, info.Pattern)
+		return stop
+	})
+	if err != stop {
+		t.Fatalf("expected stop error, got %v", err)
+	}
+	if len(seen) != 1 || seen[0] != "/a" {
+		t.Fatalf("expected walk to stop after /a, got %v", seen)
+	}
+
+	var count int
+	if err := mustFreeze(t, r).Walk(func(RouteInfo) error { count++; return nil }); err != nil {
+		t.Fatalf("walk failed: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 routes, got %d", count)
+	}
+}
+
+func TestRouter_URL(t *testing.T) {
+	r := NewRouter()
+	h := func(w http.ResponseWriter, req *http.Request) {}
+	api := r.Group("/api/v1")
+	if err := api.HandleWith(http.MethodGet, "/users/:id", h, RouteOptions{Name: "user.show"}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if err := r.HandleWith(http.MethodGet, "/files/:owner/*path", h, RouteOptions{Name: "files"}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if err := r.HandleWith(http.MethodGet, "/docs/", h, RouteOptions{Name: "docs"}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if err := r.HandleWith(http.MethodPost, "/users", h, RouteOptions{Name: "user.show"}); err == nil {
+		t.Fatalf("expected duplicate name error")
+	}
+	if err := r.POST("/users", h); err != nil {
+		t.Fatalf("failed name registration should not register the route: %v", err)
+	}
+
+	fr := mustFreeze(t, r)
+	builders := map[string]func(string, ...string) (string, error){"router": r.URL, "frozen": fr.URL}
+	for name, build := range builders {
+		cases := []struct {
+			route string
+			pairs []string
+			want  string
+		}{
+			{route: "user.show", pairs: []string{"id", "42"}, want: "/api/v1/users/42"},
+			{route: "user.show", pairs: []string{"id", "a b/c"}, want: "/api/v1/users/a%20b%2Fc"},
+			{route: "files", pairs: []string{"owner", "me", "path", "a dir/b.txt"}, want: "/files/me/a%20dir/b.txt"},
+			{route: "files", pairs: []string{"owner", "me"}, want: "/files/me/"},
+			{route: "docs", want: "/docs/"},
+		}
+		for _, tc := range cases {
+			got, err := build(tc.route, tc.pairs...)
+			if err != nil {
+				t.Fatalf("%s: build %s failed: %v", name, tc.route, err)
+			}
+			if got != tc.want {
+				t.Fatalf("%s: expected %q, got %q", name, tc.want, got)
+			}
+		}
+
+		for _, bad := range [][]string{
+			{"missing"},
+			{"user.show"},
+			{"user.show", "id"},
+			{"user.show", "id", ""},
+			{"user.show", "id", "1", "extra", "x"},
+		} {
+			if _, err := build(bad[0], bad[1:]...); err == nil {
+				t.Fatalf("%s: expected error for %v", name, bad)
+			}
+		}
+	}
+
+	var named string
+	for _, info := range r.Routes() {
+		if info.Pattern == "/api/v1/users/:id" {
+			named = info.Name
+		}
+	}
+	if named != "user.show" {
+		t.Fatalf("expected route name in listing, got %q", named)
+	}
+}
+
+func TestRouter_ParamConstraints(t *testing.T) {
+	r := NewRouter()
+	reply := func(tag string) HandleFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			var vals []string
+			for _, k := range []string{"id", "uuid", "slug", "state", "rest"} {
+				if v, ok := Param(w, k); ok {
+					vals = append(vals, k+"="+v)
+				}
+			}
+			w.Write([]byte(tag + ":" + strings.Join(vals, ",")))
+		}
+	}
+	mustGET(t, r, "/users/:id<int>", reply("int"))
+	mustGET(t, r, "/users/:uuid<uuid>", reply("uuid"))
+	mustGET(t, r, "/users/me", reply("static"))
+	mustGET(t, r, "/posts/:state<draft|published>/list", reply("enum"))
+	mustGET(t, r, "/posts/:slug<[a-z0-9-]+>/list", reply("regex"))
+	mustGET(t, r, "/posts/*rest", reply("wild"))
+
+	if err := r.GET("/users/:num<int>", reply("dup")); err == nil {
+		t.Fatalf("expected conflict for same constraint with different name")
+	}
+	if err := r.GET("/bad/:id<[a-z>", reply("bad")); err == nil {
+		t.Fatalf("expected invalid regex error")
+	}
+	if err := r.GET("/bad/:id<>", reply("bad")); err == nil {
+		t.Fatalf("expected empty constraint error")
+	}
+	if err := r.GET("/bad/*rest<int>", reply("bad")); err == nil {
+		t.Fatalf("expected wildcard constraint error")
+	}
+	if err := r.GET("/bad/:v<[^/]+>", reply("bad")); err == nil || !strings.Contains(err.Error(), "cannot contain '/'") {
+		t.Fatalf("expected slash-in-constraint error, got %v", err)
+	}
+
+	fr := mustFreeze(t, r)
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{path: "/users/42", code: http.StatusOK, body: "int:id=42"},
+		{path: "/users/-7", code: http.StatusOK, body: "int:id=-7"},
+		{path: "/users/123e4567-e89b-12d3-a456-426614174000", code: http.StatusOK, body: "uuid:uuid=123e4567-e89b-12d3-a456-426614174000"},
+		{path: "/users/me", code: http.StatusOK, body: "static:"},
+		{path: "/users/abc", code: http.StatusNotFound},
+		{path: "/posts/draft/list", code: http.StatusOK, body: "enum:state=draft"},
+		{path: "/posts/hello-world/list", code: http.StatusOK, body: "regex:slug=hello-world"},
+		{path: "/posts/Hello/list", code: http.StatusOK, body: "wild:rest=Hello/list"},
+	}
+	for _, tc := range cases {
+		for name, h := range map[string]http.Handler{"router": r, "frozen": fr} {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != tc.code {
+				t.Fatalf("%s %s: expected %d, got %d", name, tc.path, tc.code, rec.Code)
+			}
+			if tc.body != "" && rec.Body.String() != tc.body {
+				t.Fatalf("%s %s: expected %q, got %q", name, tc.path, tc.body, rec.Body.String())
+			}
+		}
+	}
+
+	routes := r.Routes()
+	for _, info := range routes {
+		if info.Pattern == "/users/:id<int>" && (len(info.Params) != 1 || info.Params[0] != "id") {
+			t.Fatalf("expected param name without constraint, got %v", info.Params)
+		}
+	}
+}
+
+func TestRouter_ParamConstraints_IgnoreCase(t *testing.T) {
+	r := NewRouter()
+	r.IgnoreCase = true
+	mustGET(t, r, "/Codes/:code<[A-Z]{3}>", func(w http.ResponseWriter, req *http.Request) {
+		code, _ := Param(w, "code")
+		w.Write([]byte(code))
+	})
+	for name, h := range map[string]http.Handler{"router": r, "frozen": mustFreeze(t, r)} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/codes/ABC", nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != "ABC" {
+			t.Fatalf("%s: expected ABC, got %d %q", name, rec.Code, rec.Body.String())
+		}
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/codes/abc", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected constraint to see original casing, got %d", name, rec.Code)
+		}
+	}
+}
+
+func TestRouter_MixedSegments(t *testing.T) {
+	r := NewRouter()
+	reply := func(tag string) HandleFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			var vals []string
+			for _, k := range []string{"file", "name", "ext", "major", "id", "from", "to"} {
+				if v, ok := Param(w, k); ok {
+					vals = append(vals, k+"="+v)
+				}
+			}
+			w.Write([]byte(tag + ":" + strings.Join(vals, ",")))
+		}
+	}
+	mustGET(t, r, "/download/:{file}.zip", reply("zip"))
+	mustGET(t, r, "/download/:{name}.:{ext}", reply("ext"))
+	mustGET(t, r, "/download/:id", reply("plain"))
+	mustGET(t, r, "/v:{major<int>}/users", reply("version"))
+	mustGET(t, r, "/range/:{from}-:{to}", reply("range"))
+
+	if err := r.GET("/download/:{other}.zip", reply("dup")); err == nil {
+		t.Fatalf("expected conflict for identical mixed structure")
+	}
+	if err := r.GET("/bad/:{a}:{b}", reply("bad")); err == nil {
+		t.Fatalf("expected error for adjacent params")
+	}
+	if err := r.GET("/bad/x:{}", reply("bad")); err == nil {
+		t.Fatalf("expected error for unnamed param")
+	}
+
+	fr := mustFreeze(t, r)
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{path: "/download/report.zip", code: http.StatusOK, body: "zip:file=report"},
+		{path: "/download/archive.tar.gz", code: http.StatusOK, body: "ext:name=archive,ext=tar.gz"},
+		{path: "/download/readme", code: http.StatusOK, body: "plain:id=readme"},
+		{path: "/download/.zip", code: http.StatusOK, body: "plain:id=.zip"},
+		{path: "/v2/users", code: http.StatusOK, body: "version:major=2"},
+		{path: "/vx/users", code: http.StatusNotFound},
+		{path: "/range/1-10", code: http.StatusOK, body: "range:from=1,to=10"},
+		{path: "/range/-10", code: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		for name, h := range map[string]http.Handler{"router": r, "frozen": fr} {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != tc.code {
+				t.Fatalf("%s %s: expected %d, got %d", name, tc.path, tc.code, rec.Code)
+			}
+			if tc.body != "" && rec.Body.String() != tc.body {
+				t.Fatalf("%s %s: expected %q, got %q", name, tc.path, tc.body, rec.Body.String())
+			}
+		}
+	}
+}
+
+func TestRouter_MixedSegments_IgnoreCaseAndURL(t *testing.T) {
+	r := NewRouter()
+	r.IgnoreCase = true
+	if err := r.HandleWith(http.MethodGet, "/Files/:{Name}.JSON", func(w http.ResponseWriter, req *http.Request) {
+		name, _ := Param(w, "Name")
+		w.Write([]byte(name))
+	}, RouteOptions{Name: "file"}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	for name, h := range map[string]http.Handler{"router": r, "frozen": mustFreeze(t, r)} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/FILES/Report.json", nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != "Report" {
+			t.Fatalf("%s: expected Report, got %d %q", name, rec.Code, rec.Body.String())
+		}
+	}
+	u, err := r.URL("file", "Name", "a b")
+	if err != nil || u != "/Files/a%20b.JSON" {
+		t.Fatalf("unexpected url %q err %v", u, err)
+	}
+	if routes := r.Routes(); len(routes) != 1 || routes[0].Kind != RouteParam || routes[0].Params[0] != "Name" {
+		t.Fatalf("unexpected routes: %+v", routes)
+	}
+}
+
+func TestRouter_OptionalSegments(t *testing.T) {
+	r := NewRouter()
+	reply := func(w http.ResponseWriter, req *http.Request) {
+		slug, ok := Param(w, "slug")
+		year, _ := Param(w, "year")
+		month, _ := Param(w, "month")
+		fmt.Fprintf(w, "slug=%s(%t) year=%s month=%s", slug, ok, year, month)
+	}
+	r.StrictParamSlash = true
+	if err := r.HandleWith(http.MethodGet, "/articles/:slug?", reply, RouteOptions{Name: "article"}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if err := r.HandleWith(http.MethodGet, "/reports(/:year<int>/:month)?", reply, RouteOptions{Name: "report"}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+
+	fr := mustFreeze(t, r)
+	cases := []struct {
+		path     string
+		code     int
+		body     string
+		location string
+	}{
+		{path: "/articles", code: http.StatusOK, body: "slug=(false) year= month="},
+		{path: "/articles/hello", code: http.StatusOK, body: "slug=hello(true) year= month="},
+		{path: "/articles/", code: http.StatusMovedPermanently, location: "/articles"},
+		{path: "/reports", code: http.StatusOK, body: "slug=(false) year= month="},
+		{path: "/reports/2024/05", code: http.StatusOK, body: "slug=(false) year=2024 month=05"},
+		{path: "/reports/2024", code: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		for name, h := range map[string]http.Handler{"router": r, "frozen": fr} {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != tc.code {
+				t.Fatalf("%s %s: expected %d, got %d", name, tc.path, tc.code, rec.Code)
+			}
+			if tc.body != "" && rec.Body.String() != tc.body {
+				t.Fatalf("%s %s: expected %q, got %q", name, tc.path, tc.body, rec.Body.String())
+			}
+			if tc.location != "" && rec.Header().Get("Location") != tc.location {
+				t.Fatalf("%s %s: expected Location %q, got %q", name, tc.path, tc.location, rec.Header().Get("Location"))
+			}
+		}
+	}
+
+	routes := r.Routes()
+	if len(routes) != 2 || routes[0].Pattern != "/articles/:slug?" || routes[1].Pattern != "/reports(/:year<int>/:month)?" {
+		t.Fatalf("expected optional routes listed once, got %+v", routes)
+	}
+	if len(fr.Routes()) != 2 {
+		t.Fatalf("expected frozen routes listed once, got %+v", fr.Routes())
+	}
+
+	for _, tc := range []struct {
+		name  string
+		pairs []string
+		want  string
+	}{
+		{name: "article", want: "/articles"},
+		{name: "article", pairs: []string{"slug", "hi"}, want: "/articles/hi"},
+		{name: "report", want: "/reports"},
+		{name: "report", pairs: []string{"year", "2024", "month", "05"}, want: "/reports/2024/05"},
+	} {
+		got, err := r.URL(tc.name, tc.pairs...)
+		if err != nil || got != tc.want {
+			t.Fatalf("URL(%s, %v): expected %q, got %q (err %v)", tc.name, tc.pairs, tc.want, got, err)
+		}
+	}
+	if _, err := r.URL("report", "year", "2024"); err == nil {
+		t.Fatalf("expected error when optional group is partially filled")
+	}
+}
+
+func TestRouter_OptionalSegments_Atomic(t *testing.T) {
+	r := NewRouter()
+	h := func(w http.ResponseWriter, req *http.Request) { w.Write([]byte("old")) }
+	mustGET(t, r, "/items/:id", h)
+
+	// Variants insert shortest first: /items goes in, then /items/:id is a duplicate,
+	// so /items must be rolled back.
+	if err := r.GET("/items/:id?", h); err == nil {
+		t.Fatalf("expected duplicate error")
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected rolled back variant to 404, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items/1", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "old" {
+		t.Fatalf("expected existing route to survive, got %d %q", rec.Code, rec.Body.String())
+	}
+	if routes := r.Routes(); len(routes) != 1 || routes[0].Pattern != "/items/:id" {
+		t.Fatalf("expected only /items/:id after rollback, got %+v", routes)
+	}
+	mustGET(t, r, "/items", h)
+
+	for _, bad := range []string{"/a/:x?/b", "/static?", "/a(/b?", "/a(/:x(/:y)?)?"} {
+		if err := r.GET(bad, h); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestRouter_Mount(t *testing.T) {
+	sub := NewRouter()
+	mustGET(t, sub, "/users/:id", func(w http.ResponseWriter, req *http.Request) {
+		id, _ := Param(w, "id")
+		_, _ = w.Write([]byte("sub:" + id))
+	})
+
+	r := NewRouter()
+	if err := r.Mount("/api/", sub); err != nil {
+		t.Fatalf("mount: %v", err)
+	}
+	var seen []string
+	echo := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		seen = append(seen, req.Method+" "+req.URL.Path+" "+req.URL.RawPath)
+	})
+	if err := r.Mount("/files", echo); err != nil {
+		t.Fatalf("mount: %v", err)
+	}
+	mustGET(t, r, "/files/special", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("explicit"))
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users/7", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "sub:7" {
+		t.Fatalf("expected sub router, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete, "PROPFIND"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/files/a/b.txt", nil))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/files", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/files/a%2Fb", nil))
+	want := []string{
+		"GET /a/b.txt ",
+		"DELETE /a/b.txt ",
+		"PROPFIND /a/b.txt ",
+		"GET / ",
+		"GET /a/b /a%2Fb",
+	}
+	if strings.Join(seen, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected forwarded requests:\n got %q\nwant %q", seen, want)
+	}
+
+	// Explicit routes under the prefix win, other methods still reach the mount.
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/files/special", nil))
+	if rec.Body.String() != "explicit" {
+		t.Fatalf("expected explicit route, got %q", rec.Body.String())
+	}
+	seen = nil
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/files/special", nil))
+	if len(seen) != 1 || rec.Header().Get("Allow") != "" {
+		t.Fatalf("expected POST to reach mount without Allow, got %q allow=%q", seen, rec.Header().Get("Allow"))
+	}
+
+	// Prefixes match whole segments only.
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/filesystem", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for /filesystem, got %d", rec.Code)
+	}
+
+	if err := r.Mount("/files/", echo); err == nil {
+		t.Fatalf("expected duplicate mount error")
+	}
+	for _, bad := range []string{"/x/:id", "/x/*rest", "/x?"} {
+		if err := r.Mount(bad, echo); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+
+	var mounts []string
+	for _, rt := range r.Routes() {
+		if rt.Kind == RouteMount {
+			mounts = append(mounts, rt.Method+" "+rt.Pattern)
+		}
+	}
+	if strings.Join(mounts, ",") != "* /api,* /files" {
+		t.Fatalf("unexpected mounts in Routes: %v", mounts)
+	}
+
+	fr, err := r.Freeze()
+	if err != nil {
+		t.Fatalf("freeze: %v", err)
+	}
+	rec = httptest.NewRecorder()
+	fr.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users/9", nil))
+	if rec.Body.String() != "sub:9" {
+		t.Fatalf("expected frozen mount, got %q", rec.Body.String())
+	}
+}
+
+func TestGroup_Mount(t *testing.T) {
+	r := NewRouter()
+	var calls []string
+	mw := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			calls = append(calls, "mw")
+			next.ServeHTTP(w, req)
+		})
+	}
+	g := r.Group("/v1", mw)
+	if err := g.Mount("/static", http.FileServer(http.FS(fstest.MapFS{
+		"app.js": &fstest.MapFile{Data: []byte("console.log(1)")},
+	}))); err != nil {
+		t.Fatalf("mount: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/static/app.js", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "console.log(1)" {
+		t.Fatalf("expected file, got %d %q", rec.Code, rec.Body.String())
+	}
+	if len(calls) != 1 {
+		t.Fatalf("expected group middleware to run once, got %d", len(calls))
+	}
+}
+
+func TestRouter_Any(t *testing.T) {
+	r := NewRouter()
+	reply := func(body string) HandleFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			_, _ = w.Write([]byte(body + ":" + req.Method))
+		}
+	}
+	if err := r.Any("/hooks/:source", reply("any")); err != nil {
+		t.Fatalf("any: %v", err)
+	}
+	if err := r.POST("/hooks/:source", reply("post")); err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	g := r.Group("/proxy")
+	if err := g.Any("/*rest", reply("proxy")); err != nil {
+		t.Fatalf("group any: %v", err)
+	}
+	if err := r.Any("/hooks/:source", reply("dup")); err == nil {
+		t.Fatalf("expected duplicate Any error")
+	}
+
+	tests := []struct {
+		method, path, want string
+	}{
+		{http.MethodGet, "/hooks/github", "any:GET"},
+		{http.MethodPost, "/hooks/github", "post:POST"},
+		{"PURGE", "/hooks/github", "any:PURGE"},
+		{http.MethodOptions, "/hooks/github", "any:OPTIONS"},
+		{http.MethodHead, "/hooks/github", "any:HEAD"},
+		{"MKCOL", "/proxy/a/b", "proxy:MKCOL"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != tt.want {
+			t.Fatalf("%s %s: expected %q, got %d %q", tt.method, tt.path, tt.want, rec.Code, rec.Body.String())
+		}
+	}
+
+	fr, err := r.Freeze()
+	if err != nil {
+		t.Fatalf("freeze: %v", err)
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		fr.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != tt.want {
+			t.Fatalf("frozen %s %s: expected %q, got %d %q", tt.method, tt.path, tt.want, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Any counts as every standard method when building Allow headers.
+	if allow, ok := r.allowedMethodsInTable("/hooks/x", &r.table, false); !ok || allow != "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS" {
+		t.Fatalf("unexpected Allow for Any route: %q", allow)
+	}
+	r2 := NewRouter()
+	if err := r2.Any("/static", reply("any")); err != nil {
+		t.Fatalf("any: %v", err)
+	}
+	if err := r2.Handle("PURGE", "/static", reply("purge")); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if allow, ok := r2.allowedMethodsInTable("/static", &r2.table, false); !ok || allow != "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS, PURGE" {
+		t.Fatalf("unexpected static Allow for Any route: %q", allow)
+	}
+	rec := httptest.NewRecorder()
+	r2.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/", nil))
+	if rec.Code != http.StatusMovedPermanently {
+		t.Fatalf("expected StrictSlash redirect to Any route, got %d", rec.Code)
+	}
+}
+
+func TestRouter_CopyOnWrite(t *testing.T) {
+	r := NewRouter()
+	mustGET(t, r, "/before", func(w http.ResponseWriter, req *http.Request) {})
+	r.EnableCopyOnWrite()
+	mustGET(t, r, "/users/:id", func(w http.ResponseWriter, req *http.Request) {
+		id, _ := Param(w, "id")
+		_, _ = w.Write([]byte(id))
+	})
+
+	for path, want := range map[string]int{"/before": http.StatusOK, "/users/7": http.StatusOK, "/missing": http.StatusNotFound} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Fatalf("%s: expected %d, got %d", path, want, rec.Code)
+		}
+	}
+
+	// A batch is published once, when it returns.
+	snap := r.snapshot.current.Load()
+	err := r.Batch(func() error {
+		for _, p := range []string{"/a", "/b", "/c"} {
+			if err := r.GET(p, func(w http.ResponseWriter, req *http.Request) {}); err != nil {
+				return err
+			}
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("expected /a to be unpublished inside batch, got %d", rec.Code)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	if r.snapshot.current.Load() == snap {
+		t.Fatalf("expected a new snapshot after batch")
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/c", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected /c after batch, got %d", rec.Code)
+	}
+}
+
+func TestRouter_CopyOnWrite_ConcurrentRegistration(t *testing.T) {
+	r := NewRouter()
+	r.EnableCopyOnWrite()
+	mustGET(t, r, "/ping", func(w http.ResponseWriter, req *http.Request) {})
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ping", nil))
+				if rec.Code != http.StatusOK {
+					t.Errorf("expected 200 during reload, got %d", rec.Code)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		mustGET(t, r, fmt.Sprintf("/tenant%d/:id", i), func(w http.ResponseWriter, req *http.Request) {})
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestRouter_RemoveAndReplace(t *testing.T) {
+	r := NewRouter()
+	reply := func(body string) HandleFunc {
+		return func(w http.ResponseWriter, req *http.Request) { _, _ = w.Write([]byte(body)) }
+	}
+	mustGET(t, r, "/plugins/billing", reply("billing"))
+	mustGET(t, r, "/plugins/:name", reply("param"))
+	mustGET(t, r, "/archive/", reply("archive"))
+	if err := r.POST("/plugins/billing", reply("post")); err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	if err := r.HandleWith(http.MethodGet, "/docs/:page?", reply("docs"), RouteOptions{Name: "docs"}); err != nil {
+		t.Fatalf("docs: %v", err)
+	}
+	serve := func(method, path string) (int, string) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	if err := r.Replace(http.MethodGet, "/plugins/billing", reply("billing-v2")); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if code, body := serve(http.MethodGet, "/plugins/billing"); code != http.StatusOK || body != "billing-v2" {
+		t.Fatalf("expected replaced handler, got %d %q", code, body)
+	}
+	if err := r.Replace(http.MethodGet, "/plugins/:id", reply("x")); err == nil {
+		t.Fatalf("expected replace of unknown pattern to fail")
+	}
+
+	if err := r.Remove(http.MethodGet, "/plugins/billing"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if code, body := serve(http.MethodGet, "/plugins/billing"); code != http.StatusOK || body != "param" {
+		t.Fatalf("expected fallback to param route, got %d %q", code, body)
+	}
+	if allow, ok := r.allowedMethodsInTable("/plugins/billing", &r.table, false); !ok || allow != "GET, HEAD, POST, OPTIONS" {
+		t.Fatalf("unexpected Allow after remove: %q", allow)
+	}
+	if err := r.Remove(http.MethodGet, "/plugins/:name"); err != nil {
+		t.Fatalf("remove param: %v", err)
+	}
+	if code, _ := serve(http.MethodGet, "/plugins/other"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 after removing param route, got %d", code)
+	}
+	if r.table.hasParams[http.MethodGet] != true {
+		t.Fatalf("expected GET to keep params for /docs/:page")
+	}
+	if err := r.Remove(http.MethodGet, "/plugins/:name"); err == nil {
+		t.Fatalf("expected second remove to fail")
+	}
+
+	// Optional routes are removed as a whole and release their name.
+	if err := r.Remove(http.MethodGet, "/docs"); err == nil {
+		t.Fatalf("expected removal of a single optional variant to fail")
+	}
+	if err := r.Remove(http.MethodGet, "/docs/:page?"); err != nil {
+		t.Fatalf("remove optional: %v", err)
+	}
+	if code, _ := serve(http.MethodGet, "/docs/intro"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 after removing optional route, got %d", code)
+	}
+	if _, err := r.URL("docs"); err == nil {
+		t.Fatalf("expected name to be released")
+	}
+	if r.table.hasParams[http.MethodGet] || r.table.anyParams {
+		t.Fatalf("expected param indexes to be cleared")
+	}
+
+	if err := r.Remove(http.MethodGet, "/archive/"); err != nil {
+		t.Fatalf("remove trailing: %v", err)
+	}
+	if r.table.hasTrailing {
+		t.Fatalf("expected hasTrailing to be cleared")
+	}
+	if got := len(r.Routes()); got != 1 || r.routesCount != 1 {
+		t.Fatalf("expected only POST route left, got %d routes (count %d)", got, r.routesCount)
+	}
+
+	// Removed paths can be registered again.
+	mustGET(t, r, "/plugins/:id", reply("again"))
+	if code, body := serve(http.MethodGet, "/plugins/x"); code != http.StatusOK || body != "again" {
+		t.Fatalf("expected re-registered route, got %d %q", code, body)
+	}
+}
+
+func TestGroup_RemoveHostRoute(t *testing.T) {
+	r := NewRouter()
+	tenant := r.Host("acme.example.com").Group("/api")
+	if err := tenant.GET("/reports", func(w http.ResponseWriter, req *http.Request) {}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	r.EnableCopyOnWrite()
+
+	req := httptest.NewRequest(http.MethodGet, "http://acme.example.com/api/reports", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if err := tenant.Remove(http.MethodGet, "/reports"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after remove, got %d", rec.Code)
+	}
+	if len(r.hosts) != 0 {
+		t.Fatalf("expected empty host table to be dropped")
+	}
+}
+
+func TestRouter_RemoveConcurrentServe(t *testing.T) {
+	r := NewRouter()
+	h := func(w http.ResponseWriter, req *http.Request) {}
+	mustGET(t, r, "/stable/:id", h)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			for _, p := range []string{"/stable/1", "/toggle/1", "/toggle"} {
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, p, nil))
+				if p == "/stable/1" && rec.Code != http.StatusOK {
+					t.Errorf("expected stable route to serve, got %d", rec.Code)
+					return
+				}
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		mustGET(t, r, "/toggle/:id", h)
+		mustGET(t, r, "/toggle", h)
+		if err := r.Remove(http.MethodGet, "/toggle/:id"); err != nil {
+			t.Fatalf("remove: %v", err)
+		}
+		if err := r.Remove(http.MethodGet, "/toggle"); err != nil {
+			t.Fatalf("remove: %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestRouter_HostPatterns(t *testing.T) {
+	r := NewRouter()
+	reply := func(prefix string) HandleFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			tenant, _ := Param(w, "tenant")
+			id, _ := Param(w, "id")
+			_, _ = w.Write([]byte(prefix + ":" + tenant + ":" + id))
+		}
+	}
+	if err := r.Host(":tenant.example.com").GET("/", reply("tenant")); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := r.Host(":tenant.example.com").GET("/users/:id", reply("user")); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := r.Host("*.eu.example.com").GET("/", reply("eu")); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := r.Host("www.example.com").GET("/", reply("www")); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	mustGET(t, r, "/", reply("default"))
+
+	tests := []struct{ host, path, want string }{
+		{"acme.example.com", "/", "tenant:acme:"},
+		{"ACME.example.com:8080", "/users/7", "user:acme:7"},
+		{"www.example.com", "/", "www::"},
+		{"shop.eu.example.com", "/", "eu::"},
+		{"a.b.example.com", "/", "default::"},
+		{"example.com", "/", "default::"},
+	}
+	fr, err := r.Freeze()
+	if err != nil {
+		t.Fatalf("freeze: %v", err)
+	}
+	for _, h := range []http.Handler{r, fr} {
+		for _, tt := range tests {
+			req := httptest.NewRequest(http.MethodGet, "http://"+tt.host+tt.path, nil)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK || rec.Body.String() != tt.want {
+				t.Fatalf("%T %s%s: expected %q, got %d %q", h, tt.host, tt.path, tt.want, rec.Code, rec.Body.String())
+			}
+		}
+	}
+
+	var hosts []string
+	for _, rt := range r.Routes() {
+		hosts = append(hosts, rt.Host)
+	}
+	if !strings.Contains(strings.Join(hosts, ","), ":tenant.example.com") {
+		t.Fatalf("expected host pattern in Routes, got %v", hosts)
+	}
+
+	for _, bad := range []struct{ host, pattern string }{
+		{"*.example.com", "/x"},             // same suffix as :tenant
+		{"api.*.example.com", "/x"},         // wildcard not first
+		{":tenant.example.com", "/:tenant"}, // shadows host param
+		{"*", "/x"},                         // no suffix
+	} {
+		if err := r.Host(bad.host).GET(bad.pattern, reply("bad")); err == nil {
+			t.Fatalf("expected error for host %s pattern %s", bad.host, bad.pattern)
+		}
+	}
+}
+
+func TestRouter_MatchedRoute(t *testing.T) {
+	r := NewRouter()
+	var labels []string
+	metrics := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, req)
+			info, ok := MatchedRoute(w)
+			labels = append(labels, fmt.Sprintf("%v|%s|%s|%s", ok, info.Host, info.Pattern, info.Name))
+		})
+	}
+	if err := r.Use(metrics); err != nil {
+		t.Fatalf("use: %v", err)
+	}
+	var buf strings.Builder
+	if err := r.Use(middleware.LoggerWith(middleware.LoggerOptions{Writer: &buf, JSON: true})); err != nil {
+		t.Fatalf("use: %v", err)
+	}
+	h := func(w http.ResponseWriter, req *http.Request) {}
+	mustGET(t, r, "/health", h)
+	if err := r.HandleWith(http.MethodGet, "/users/:id", h, RouteOptions{Name: "user.show"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := r.Host(":tenant.example.com").GET("/files/*path", h); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	fr, err := r.Freeze()
+	if err != nil {
+		t.Fatalf("freeze: %v", err)
+	}
+	for _, srv := range []http.Handler{r, fr} {
+		labels = labels[:0]
+		for _, target := range []string{"/health", "/users/42", "http://acme.example.com/files/a/b"} {
+			srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+		}
+		want := "true||/health||true||/users/:id|user.show|true|:tenant.example.com|/files/*path|"
+		if got := strings.Join(labels, "|"); got != want {
+			t.Fatalf("%T: unexpected labels:\n got %s\nwant %s", srv, got, want)
+		}
+	}
+	if !strings.Contains(buf.String(), `"route":"/users/:id"`) {
+		t.Fatalf("expected logger to record route, got %s", buf.String())
+	}
+
+	// Handlers of param routes see their route without middleware.
+	r2 := NewRouter()
+	mustGET(t, r2, "/posts/:slug", func(w http.ResponseWriter, req *http.Request) {
+		info, _ := MatchedRoute(w)
+		_, _ = w.Write([]byte(info.Pattern))
+	})
+	rec := httptest.NewRecorder()
+	r2.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts/hello", nil))
+	if rec.Body.String() != "/posts/:slug" {
+		t.Fatalf("expected pattern in handler, got %q", rec.Body.String())
+	}
+}
+
+func TestRouter_UsePathValue(t *testing.T) {
+	r := NewRouter()
+	r.UsePathValue = true
+	h := func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(req.PathValue("tenant") + "|" + req.PathValue("id") + "|" + req.PathValue("rest")))
+	}
+	if err := r.Host(":tenant.example.com").GET("/users/:id", h); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	mustGET(t, r, "/files/*rest", h)
+	// http.TimeoutHandler replaces the writer, so Param(w) no longer works there.
+	mustGET(t, r, "/slow/:id", http.TimeoutHandler(http.HandlerFunc(h), time.Second, "timeout").ServeHTTP)
+
+	fr, err := r.Freeze()
+	if err != nil {
+		t.Fatalf("freeze: %v", err)
+	}
+	tests := []struct{ target, want string }{
+		{"http://acme.example.com/users/7", "acme|7|"},
+		{"/files/a/b.txt", "||a/b.txt"},
+		{"/slow/9", "|9|"},
+	}
+	for _, srv := range []http.Handler{r, fr} {
+		for _, tt := range tests {
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Body.String() != tt.want {
+				t.Fatalf("%T %s: expected %q, got %q", srv, tt.target, tt.want, rec.Body.String())
+			}
+		}
+	}
+
+	r.UsePathValue = false
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/files/x", nil))
+	if rec.Body.String() != "||" {
+		t.Fatalf("expected no path values when disabled, got %q", rec.Body.String())
+	}
+}
+
+func TestRouter_ServeMuxPatterns(t *testing.T) {
+	r := NewRouter()
+	reply := func(name string) HandleFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			_, _ = fmt.Fprintf(w, "%s:%s:%s", name, req.PathValue("id"), req.PathValue("path"))
+		}
+	}
+	for pattern, h := range map[string]HandleFunc{
+		"GET /users/{id}":         reply("user"),
+		"POST /users/{id}/avatar": reply("avatar"),
+		"/files/{path...}":        reply("files"),
+		"GET /static/":            reply("static"),
+		"GET /{$}":                reply("root"),
+		"GET api.example.com/{$}": reply("api"),
+		"GET /docs/{id}/edit/{$}": reply("edit"),
+	} {
+		if err := r.HandleFunc(pattern, h); err != nil {
+			t.Fatalf("register %q: %v", pattern, err)
+		}
+	}
+	// Brace paths also work with Handle and method helpers.
+	if err := r.GET("/orders/{id}", reply("order")); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := r.Group("/v1").HandleFunc("DELETE /items/{id}", reply("item")); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	tests := []struct {
+		method, target string
+		code           int
+		body           string
+	}{
+		{http.MethodGet, "/users/7", 200, "user:7:"},
+		{http.MethodHead, "/users/7", 200, ""},
+		{http.MethodPost, "/users/7/avatar", 200, "avatar:7:"},
+		{"PURGE", "/files/a/b.txt", 200, "files::a/b.txt"},
+		{http.MethodGet, "/static/css/site.css", 200, "static::"},
+		{http.MethodGet, "/static/", 200, "static::"},
+		{http.MethodGet, "/", 200, "root::"},
+		{http.MethodGet, "/nope", 404, ""},
+		{http.MethodGet, "http://api.example.com/", 200, "api::"},
+		{http.MethodGet, "/docs/3/edit/", 200, "edit:3:"},
+		{http.MethodGet, "/orders/9", 200, "order:9:"},
+		{http.MethodDelete, "/v1/items/5", 200, "item:5:"},
+		{http.MethodPut, "/users/7", 405, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.code || (tt.body != "" && rec.Body.String() != tt.body) {
+			t.Fatalf("%s %s: expected %d %q, got %d %q", tt.method, tt.target, tt.code, tt.body, rec.Code, rec.Body.String())
+		}
+	}
+
+	if err := r.Remove(http.MethodGet, "/orders/{id}"); err != nil {
+		t.Fatalf("remove brace pattern: %v", err)
+	}
+
+	for _, bad := range []string{
+		"GET users",
+		"GET /a/{$}/b",
+		"GET /a/{rest...}/b",
+		"GET /a/x{id}",
+		"GET /a/{}",
+		"GET /a/{i-d}",
+		"GET /a/:id",
+		"GET /users/{name}", // conflicts with {id}
+	} {
+		if err := r.HandleFunc(bad, reply("bad")); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+	if err := r.Group("/v1").HandleFunc("GET example.com/x", reply("bad")); err == nil {
+		t.Fatalf("expected error for host in group pattern")
+	}
+}
+
+func TestRouter_Analyze(t *testing.T) {
+	h := func(w http.ResponseWriter, _ *http.Request) {}
+	r := NewRouter()
+	mustOK := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+	mustOK(r.GET("/users/:id", h))
+	mustOK(r.GET("/users/new", h))
+	mustOK(r.GET("/items/:id<.+>", h))
+	mustOK(r.GET("/items/:name", h))
+	mustOK(r.GET("/files/*path", h))
+	mustOK(r.GET("/files/readme", h))
+	mustOK(r.GET("/static/*path", h))
+	mustOK(r.POST("/static/upload", h))
+	mustOK(r.GET("/About", h))
+	mustOK(r.GET("/about", h))
+	mustOK(r.GET("/docs", h))
+	mustOK(r.POST("/docs/", h))
+	mustOK(r.GET("/orders/:id<int>", h))
+	mustOK(r.GET("/orders/:id<uint>", h))
+	mustOK(r.GET("/clean/:id", h))
+
+	got := make(map[string]bool)
+	for _, f := range r.Analyze() {
+		got[f.Kind.String()+" "+f.Route.Pattern+" "+f.Other.Pattern] = true
+	}
+	want := []string{
+		"shadowed /users/:id /users/new",
+		"unreachable /items/:name /items/:id<.+>",
+		"shadowed /files/*path /files/readme",
+		"wildcard-swallow /static/upload /static/*path",
+		"case-collision /about /About",
+		"trailing-slash /docs/ /docs",
+		"unreachable /orders/:id<uint> /orders/:id<int>",
+	}
+	for _, w := range want {
+		if !got[w] {
+			t.Errorf("missing finding %q in %v", w, got)
+		}
+	}
+	for k := range got {
+		if strings.Contains(k, "/clean/") {
+			t.Errorf("unexpected finding %q", k)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("findings = %v, want %d", got, len(want))
+	}
+
+	r = NewRouter()
+	r.IgnoreCase = true
+	mustOK(r.GET("/lang/:code<en|fr>", h))
+	mustOK(r.Any("/api/:v", h))
+	mustOK(r.GET("/api/health", h))
+	findings := r.Analyze()
+	if len(findings) != 2 || findings[0].Kind != FindingShadowed || findings[1].Kind != FindingCaseCollision {
+		t.Fatalf("findings = %v", findings)
+	}
+}
+
+func TestRouter_OpenAPI(t *testing.T) {
+	h := func(w http.ResponseWriter, _ *http.Request) {}
+	r := NewRouter()
+	mustOK := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+	mustOK(r.HandleWith(http.MethodGet, "/users/:id<int>", h, RouteOptions{
+		Name: "getUser",
+		Doc: &RouteDoc{
+			Summary:   "Get a user",
+			Tags:      []string{"users"},
+			Responses: map[int]json.RawMessage{200: json.RawMessage(`{"$ref":"#/components/schemas/User"}`), 404: nil},
+		},
+	}))
+	mustOK(r.HandleWith(http.MethodPost, "/users", h, RouteOptions{Doc: &RouteDoc{
+		OperationID: "createUser",
+		RequestBody: json.RawMessage(`{"type":"object"}`),
+		Security:    []map[string][]string{{"bearer": {"users:write"}}},
+	}}))
+	mustOK(r.HandleWith(http.MethodGet, "/health", h, RouteOptions{Doc: &RouteDoc{Security: []map[string][]string{}}}))
+	mustOK(r.GET("/files/*path", h))
+	mustOK(r.GET("/articles/:slug?", h))
+	mustOK(r.GET("/download/:{file}.zip", h))
+	mustOK(r.Any("/echo", h))
+	mustOK(r.POST("/echo", h))
+	mustOK(r.Handle("PURGE", "/cache", h))
+	mustOK(r.Mount("/legacy", http.NotFoundHandler()))
+	mustOK(r.Host("api.example.com").GET("/only-host", h))
+	mustOK(r.DELETE("/users/:uid", h)) // same template as /users/:id<int>
+
+	if err := r.HandleWith(http.MethodGet, "/bad", h, RouteOptions{Doc: &RouteDoc{RequestBody: json.RawMessage(`{`)}}); err == nil {
+		t.Fatalf("expected invalid schema error")
+	}
+
+	cfg := OpenAPIConfig{
+		Info:       OpenAPIInfo{Title: "Test API", Version: "1.0"},
+		Servers:    []string{"https://api.example.com"},
+		Security:   []map[string][]string{{"bearer": {}}},
+		Components: json.RawMessage(`{"schemas":{"User":{"type":"object"}}}`),
+	}
+	data, err := OpenAPIJSON(r.Routes(), cfg)
+	if err != nil {
+		t.Fatalf("OpenAPIJSON: %v", err)
+	}
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Fatalf("openapi = %q", doc.OpenAPI)
+	}
+	var paths []string
+	for p, item := range doc.Paths {
+		var methods []string
+		for m := range item {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		paths = append(paths, p+" "+strings.Join(methods, ","))
+	}
+	sort.Strings(paths)
+	want := []string{
+		"/articles get",
+		"/articles/{slug} get",
+		"/download/{file}.zip get",
+		"/echo delete,get,head,options,patch,post,put",
+		"/files/{path} get",
+		"/health get",
+		"/users post",
+		"/users/{id} delete,get",
+	}
+	if strings.Join(paths, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("paths:\n%s\nwant:\n%s", strings.Join(paths, "\n"), strings.Join(want, "\n"))
+	}
+
+	op := func(path, method string) string {
+		var b bytes.Buffer
+		_ = json.Compact(&b, doc.Paths[path][method])
+		return b.String()
+	}
+	for _, tc := range []struct{ path, method, want string }{
+		{"/users/{id}", "get", `"operationId":"getUser"`},
+		{"/users/{id}", "get", `"schema":{"type":"integer"}`},
+		{"/users/{id}", "get", `"404":{"description":"Not Found"}`},
+		{"/users/{id}", "get", `"tags":["users"]`},
+		{"/users", "post", `"security":[{"bearer":["users:write"]}]`},
+		{"/users", "post", `"requestBody":{"required":true,"content":{"application/json":{"schema":{"type":"object"}}}}`},
+		{"/health", "get", `"security":[]`},
+		{"/files/{path}", "get", `"name":"path","in":"path"`},
+		{"/users/{id}", "delete", `"parameters":[{"name":"id","in":"path","required":true,"schema":{"type":"string"}}]`},
+		{"/echo", "get", `"responses":{"default":{"description":"Default response"}}`},
+	} {
+		if got := op(tc.path, tc.method); !strings.Contains(got, tc.want) {
+			t.Errorf("%s %s: missing %s in %s", tc.method, tc.path, tc.want, got)
+		}
+	}
+	if strings.Contains(op("/echo", "put"), "operationId") {
+		t.Errorf("Any route must not repeat operation IDs: %s", op("/echo", "put"))
+	}
+
+	hostDoc, err := OpenAPIJSON(r.Routes(), OpenAPIConfig{Host: "api.example.com"})
+	if err != nil || !strings.Contains(string(hostDoc), `"/only-host"`) || strings.Contains(string(hostDoc), `"/users"`) {
+		t.Fatalf("host document = %s, %v", hostDoc, err)
+	}
+
+	fr, err := r.Freeze()
+	if err != nil {
+		t.Fatalf("Freeze: %v", err)
+	}
+	frozenData, err := OpenAPIJSON(fr.Routes(), cfg)
+	if err != nil || string(frozenData) != string(data) {
+		t.Fatalf("frozen document differs: %v", err)
+	}
+
+	yml, err := OpenAPIYAML(r.Routes(), cfg)
+	if err != nil {
+		t.Fatalf("OpenAPIYAML: %v", err)
+	}
+	for _, want := range []string{
+		"openapi: \"3.1.0\"\n",
+		"info:\n  title: Test API\n  version: \"1.0\"\n",
+		"servers:\n  - url: \"https://api.example.com\"\n",
+		"  /users/{id}:\n    delete:\n",
+		"    get:\n      operationId: getUser\n",
+		"          in: path\n          required: true\n          schema:\n            type: integer\n",
+		"        \"404\":\n          description: Not Found\n",
+		"security:\n  - bearer: []\n",
+	} {
+		if !strings.Contains(string(yml), want) {
+			t.Errorf("yaml missing %q:\n%s", want, yml)
+		}
+	}
+}
+
+// samplePath fills a pattern's params with their names and wildcards with "a/b".
+func samplePath(pattern string) string {
+	parts := strings.Split(pattern, "/")
+	for i, part := range parts {
+		switch {
+		case strings.HasPrefix(part, ":"):
+			parts[i] = part[1:]
+		case strings.HasPrefix(part, "*"):
+			parts[i] = "a/b"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func TestFrozenRouter_FlatMatcher(t *testing.T) {
+	extra := []struct{ method, path string }{
+		{"GET", "/files/:{name}.zip"},
+		{"GET", "/files/:{name}.:{ext}"},
+		{"GET", "/v:{major}/api"},
+		{"GET", "/items/:id<int>"},
+		{"GET", "/items/:slug<[a-z-]+>"},
+		{"GET", "/items/:any"},
+		{"GET", "/opt/:a?"},
+		{"GET", "/wild/*rest"},
+		{"GET", "/wild/special"},
+		{"GET", "/deep/:a/:b/x"},
+		{"GET", "/deep/:a/y/z"},
+		{"GET", "/slash/"},
+		{"POST", "/slash"},
+		{MethodAny, "/any/:id"},
+		{"PURGE", "/cache/:key"},
+	}
+	routes := append(append([]struct{ method, path string }(nil), githubAPI...), extra...)
+
+	segments := []string{"repos", "user", "users", "a", "1", "42", "x", "y", "z", "files", "f.zip", "f.tar",
+		"v2", "api", "items", "abc", "opt", "wild", "deep", "special", "events", "git", "refs", "issues",
+		"slash", "any", "cache", "REPOS", "Events"}
+	rnd := rand.New(rand.NewSource(1))
+	var probes []string
+	for _, rt := range routes {
+		probes = append(probes, samplePath(rt.path), samplePath(rt.path)+"/")
+	}
+	for i := 0; i < 1500; i++ {
+		var b strings.Builder
+		for n := rnd.Intn(7); n >= 0; n-- {
+			b.WriteString("/" + segments[rnd.Intn(len(segments))])
+		}
+		if rnd.Intn(5) == 0 {
+			b.WriteString("/")
+		}
+		probes = append(probes, b.String())
+	}
+
+	for _, cfg := range []struct {
+		name                    string
+		strictSlash, ignoreCase bool
+	}{
+		{"strict", true, false},
+		{"lenient", false, false},
+		{"ignore-case", true, true},
+	} {
+		t.Run(cfg.name, func(t *testing.T) {
+			r := NewRouter()
+			r.StrictSlash = cfg.strictSlash
+			r.IgnoreCase = cfg.ignoreCase
+			for _, rt := range routes {
+				pattern := rt.method + " " + rt.path
+				err := r.Handle(rt.method, rt.path, func(w http.ResponseWriter, req *http.Request) {
+					_, _ = io.WriteString(w, pattern)
+					info, _ := MatchedRoute(w)
+					for _, name := range info.Params {
+						v, _ := Param(w, name)
+						_, _ = io.WriteString(w, " "+name+"="+v)
+					}
+				})
+				if err != nil {
+					t.Fatalf("register %s: %v", pattern, err)
+				}
+			}
+			tree := mustFreeze(t, r)
+			flat, err := r.FreezeWith(FreezeOptions{Matcher: MatcherFlat})
+			if err != nil {
+				t.Fatalf("FreezeWith: %v", err)
+			}
+
+			for _, rt := range githubAPI {
+				w := httptest.NewRecorder()
+				flat.ServeHTTP(w, httptest.NewRequest(rt.method, samplePath(rt.path), nil))
+				if got := w.Body.String(); !strings.HasPrefix(got, rt.method+" "+rt.path) {
+					t.Fatalf("%s %s served %q", rt.method, samplePath(rt.path), got)
+				}
+			}
+			for _, path := range probes {
+				for _, method := range []string{"GET", "HEAD", "POST", "DELETE", "PURGE"} {
+					wt, wf := httptest.NewRecorder(), httptest.NewRecorder()
+					tree.ServeHTTP(wt, httptest.NewRequest(method, path, nil))
+					flat.ServeHTTP(wf, httptest.NewRequest(method, path, nil))
+					if wt.Code != wf.Code || wt.Body.String() != wf.Body.String() ||
+						wt.Header().Get("Allow") != wf.Header().Get("Allow") || wt.Header().Get("Location") != wf.Header().Get("Location") {
+						t.Fatalf("%s %s: tree %d %q allow=%q, flat %d %q allow=%q", method, path,
+							wt.Code, wt.Body.String(), wt.Header().Get("Allow"), wf.Code, wf.Body.String(), wf.Header().Get("Allow"))
+					}
+				}
+			}
+		})
+	}
+
+	if _, err := NewRouter().FreezeWith(FreezeOptions{Matcher: FrozenMatcher(9)}); err == nil {
+		t.Fatalf("expected error for unknown matcher")
+	}
+}
+
+func TestFrozenRouter_Manifest(t *testing.T) {
+	reply := func(id string) HandleFunc {
+		return func(w http.ResponseWriter, _ *http.Request) { _, _ = io.WriteString(w, id) }
+	}
+	r := NewRouter()
+	for _, rt := range []struct{ method, pattern string }{
+		// Overlapping constrained siblings: the one registered first wins.
+		{"GET", "/users/:num<int>"},
+		{"GET", "/users/:hex<[0-9a-f]+>"},
+		{"GET", "/users/me"},
+		{"POST", "/users"},
+		{"GET", "/articles/:slug?"},
+		{MethodAny, "/any/*rest"},
+	} {
+		if err := r.Handle(rt.method, rt.pattern, reply(rt.method+" "+rt.pattern)); err != nil {
+			t.Fatalf("register %s %s failed: %v", rt.method, rt.pattern, err)
+		}
+	}
+	doc := &RouteDoc{
+		Summary:   "Get order",
+		Responses: map[int]json.RawMessage{200: json.RawMessage(`{"type":"object"}`), 204: nil},
+		Security:  []map[string][]string{}, // public
+	}
+	if err := r.HandleWith("GET", "/orders/:id", reply("order"), RouteOptions{Name: "order", Doc: doc}); err != nil {
+		t.Fatalf("named route failed: %v", err)
+	}
+	if err := r.Host("API.example.com").GET("/status", reply("GET api.example.com/status")); err != nil {
+		t.Fatalf("host route failed: %v", err)
+	}
+	if err := r.Host("*.example.com").GET("/", reply("GET *.example.com/")); err != nil {
+		t.Fatalf("host pattern route failed: %v", err)
+	}
+	if err := r.Mount("/static", http.HandlerFunc(reply("* /static"))); err != nil {
+		t.Fatalf("mount failed: %v", err)
+	}
+
+	fr, err := r.FreezeWith(FreezeOptions{Matcher: MatcherFlat})
+	if err != nil {
+		t.Fatalf("freeze failed: %v", err)
+	}
+	data, err := fr.ExportManifest()
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	again, _ := r.FreezeWith(FreezeOptions{Matcher: MatcherFlat})
+	if data2, _ := again.ExportManifest(); !bytes.Equal(data, data2) {
+		t.Fatalf("freeze is not deterministic:\n%s\n---\n%s", data, data2)
+	}
+
+	m := fr.Manifest()
+	if m.Version != ManifestVersion || m.Matcher != "flat" || !m.StrictSlash || len(m.Routes) != 10 {
+		t.Fatalf("unexpected manifest header: %+v", m)
+	}
+	handlers := make(map[string]HandleFunc)
+	for _, rt := range m.Routes {
+		handlers[rt.Handler] = reply(rt.Handler)
+	}
+	loaded, err := LoadManifest(data, handlers)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if data2, _ := loaded.ExportManifest(); !bytes.Equal(data, data2) {
+		t.Fatalf("round trip changed the manifest:\n%s\n---\n%s", data, data2)
+	}
+
+	for _, tc := range []struct{ method, host, path, want string }{
+		{"GET", "", "/users/12", "GET /users/:num<int>"},
+		{"GET", "", "/users/ab", "GET /users/:hex<[0-9a-f]+>"},
+		{"GET", "", "/users/me", "GET /users/me"},
+		{"POST", "", "/users", "POST /users"},
+		{"GET", "", "/articles", "GET /articles/:slug?"},
+		{"GET", "", "/articles/go", "GET /articles/:slug?"},
+		{"DELETE", "", "/any/x/y", "* /any/*rest"},
+		{"GET", "", "/orders/7", "order"},
+		{"GET", "api.example.com", "/status", "GET api.example.com/status"},
+		{"GET", "eu.example.com", "/", "GET *.example.com/"},
+		{"GET", "", "/static/app.js", "* /static"},
+	} {
+		for name, h := range map[string]http.Handler{"exported": fr, "loaded": loaded} {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.host != "" {
+				req.Host = tc.host
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != http.StatusOK || w.Body.String() != tc.want {
+				t.Fatalf("%s %s %s%s: got %d %q, want %q", name, tc.method, tc.host, tc.path, w.Code, w.Body.String(), tc.want)
+			}
+		}
+	}
+	for _, info := range loaded.Routes() {
+		if info.Name != "order" {
+			continue
+		}
+		var schema bytes.Buffer
+		if info.Doc == nil || info.Doc.Summary != "Get order" || info.Doc.Security == nil || info.Doc.Responses[204] != nil ||
+			json.Compact(&schema, info.Doc.Responses[200]) != nil || schema.String() != `{"type":"object"}` {
+			t.Fatalf("doc not restored: %+v", info.Doc)
+		}
+	}
+
+	delete(handlers, "order")
+	if _, err := LoadManifest(data, handlers); err == nil || !strings.Contains(err.Error(), "missing handler: order") {
+		t.Fatalf("expected missing handler error, got %v", err)
+	}
+	bad := m
+	bad.Version = 2
+	if _, err := bad.Build(handlers); err == nil {
+		t.Fatal("expected unsupported version error")
+	}
+	bad = m
+	bad.Matcher = "regex"
+	if _, err := bad.Build(handlers); err == nil {
+		t.Fatal("expected unknown matcher error")
+	}
+	if _, err := LoadManifest([]byte("{"), handlers); err == nil {
+		t.Fatal("expected invalid manifest error")
+	}
+}
+
+func TestRouter_Version(t *testing.T) {
+	reply := func(id string) HandleFunc {
+		return func(w http.ResponseWriter, _ *http.Request) { _, _ = io.WriteString(w, id) }
+	}
+	r := NewRouter()
+	r.Versioning = VersionOptions{MediaType: "application/vnd.acme", Header: "API-Version", Query: "version", Default: "v1"}
+	mustGET(t, r, "/health", reply("health"))
+	if err := r.Version("v1").GET("/users/:id", reply("v1")); err != nil {
+		t.Fatalf("v1 route failed: %v", err)
+	}
+	if err := r.Version("v2").Group("/users").GET("/:id", reply("v2")); err != nil {
+		t.Fatalf("v2 route failed: %v", err)
+	}
+	if err := r.Version("v2").GET("/status", reply("status v2")); err != nil {
+		t.Fatalf("static v2 route failed: %v", err)
+	}
+
+	serve := func(h http.Handler, target string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Add(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	cases := []struct {
+		target string
+		header []string
+		code   int
+		body   string
+	}{
+		{"/health", nil, http.StatusOK, "health"},
+		{"/users/1", nil, http.StatusOK, "v1"},
+		{"/users/1", []string{"Accept", "application/vnd.acme.v2+json"}, http.StatusOK, "v2"},
+		{"/users/1", []string{"Accept", "application/json, application/vnd.acme.v1+json;q=0.5, application/vnd.acme.v2+json;q=0.9"}, http.StatusOK, "v2"},
+		{"/users/1", []string{"Accept", "application/vnd.acme.v2+json", "API-Version", "v1"}, http.StatusOK, "v2"},
+		{"/users/1", []string{"API-Version", "v2"}, http.StatusOK, "v2"},
+		{"/users/1?version=v2", nil, http.StatusOK, "v2"},
+		{"/users/1?version=v1", []string{"API-Version", "v2"}, http.StatusOK, "v2"},
+		{"/users/1", []string{"API-Version", "v3"}, http.StatusNotAcceptable, ""},
+		{"/users/1", []string{"Accept", "application/vnd.acme.v3+json"}, http.StatusNotAcceptable, ""},
+		{"/status", []string{"API-Version", "v2"}, http.StatusOK, "status v2"},
+		{"/status", nil, http.StatusNotAcceptable, ""}, // default v1 is not served
+	}
+	fr, err := r.Freeze()
+	if err != nil {
+		t.Fatalf("freeze failed: %v", err)
+	}
+	for _, h := range []http.Handler{r, fr} {
+		for _, tc := range cases {
+			w := serve(h, tc.target, tc.header...)
+			if w.Code != tc.code || w.Body.String() != tc.body {
+				t.Fatalf("%s %v: got %d %q, want %d %q", tc.target, tc.header, w.Code, w.Body.String(), tc.code, tc.body)
+			}
+		}
+	}
+	if w := serve(r, "/users/1"); w.Header().Get("Vary") != "Accept, Api-Version" {
+		t.Fatalf("unexpected Vary header: %q", w.Header().Get("Vary"))
+	}
+	if w := serve(r, "/health"); w.Header().Get("Vary") != "" {
+		t.Fatalf("unversioned route must not set Vary, got %q", w.Header().Get("Vary"))
+	}
+
+	// Versions added later do not leak into routers frozen before.
+	if err := r.Version("v3").GET("/users/:id", reply("v3")); err != nil {
+		t.Fatalf("v3 route failed: %v", err)
+	}
+	if w := serve(r, "/users/1", "API-Version", "v3"); w.Body.String() != "v3" {
+		t.Fatalf("expected v3 on router, got %d %q", w.Code, w.Body.String())
+	}
+	if w := serve(fr, "/users/1", "API-Version", "v3"); w.Code != http.StatusNotAcceptable {
+		t.Fatalf("expected 406 on frozen router, got %d", w.Code)
+	}
+
+	for name, err := range map[string]error{
+		"duplicate version":   r.Version("v1").GET("/users/:id", reply("again")),
+		"unversioned after":   r.GET("/users/:id", reply("plain")),
+		"versioned after":     r.Version("v1").GET("/health", reply("health v1")),
+		"invalid version":     r.Version("v 1").GET("/orders", reply("orders")),
+		"name on later":       r.Version("v4").HandleWith(http.MethodGet, "/users/:id", reply("v4"), RouteOptions{Name: "user"}),
+		"replace versioned":   r.Replace(http.MethodGet, "/users/:id", reply("replaced")),
+		"mount in version":    r.Version("v1").Mount("/static", http.NotFoundHandler()),
+		"version on mux path": r.Version("v1").GET("/users/{id}", reply("mux")),
+	} {
+		if err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+
+	// Without a default, requests must name a version; NotAcceptable customizes the 406.
+	r2 := NewRouter()
+	r2.Versioning = VersionOptions{Header: "API-Version", NotAcceptable: func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "unsupported version", http.StatusNotAcceptable)
+	}}
+	if err := r2.Version("2024-01-01").GET("/items", reply("dated")); err != nil {
+		t.Fatalf("dated version failed: %v", err)
+	}
+	if w := serve(r2, "/items"); w.Code != http.StatusNotAcceptable || !strings.Contains(w.Body.String(), "unsupported version") {
+		t.Fatalf("expected custom 406, got %d %q", w.Code, w.Body.String())
+	}
+	if w := serve(r2, "/items", "API-Version", "2024-01-01"); w.Body.String() != "dated" {
+		t.Fatalf("expected dated version, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Manifests keep versions and their selection options.
+	data, err := fr.ExportManifest()
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	handlers := map[string]HandleFunc{"GET /health": reply("health")}
+	for _, id := range []string{"GET /users/:id@v1", "GET /users/:id@v2", "GET /status@v2"} {
+		handlers[id] = reply(id)
+	}
+	loaded, err := LoadManifest(data, handlers)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if w := serve(loaded, "/users/1?version=v2"); w.Body.String() != "GET /users/:id@v2" {
+		t.Fatalf("expected v2 from loaded manifest, got %d %q", w.Code, w.Body.String())
+	}
+	if data2, _ := loaded.ExportManifest(); !bytes.Equal(data, data2) {
+		t.Fatalf("round trip changed the manifest:\n%s\n---\n%s", data, data2)
+	}
+}
+
+func TestRouter_ContentNegotiation(t *testing.T) {
+	ok := func(w http.ResponseWriter, _ *http.Request) { _, _ = io.WriteString(w, "ok") }
+	r := NewRouter()
+	if err := r.HandleWith(http.MethodPost, "/users", ok, RouteOptions{
+		Consumes: []string{"application/json", "application/*+json; charset=utf-8"},
+		Produces: []string{"application/json"},
+	}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if err := r.HandleWith(http.MethodPatch, "/users/:id", ok, RouteOptions{Consumes: []string{"application/merge-patch+json"}}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if err := r.HandleWith(http.MethodGet, "/report", ok, RouteOptions{Produces: []string{"text/csv", "application/json"}}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if err := r.HandleWith(http.MethodPut, "/upload", ok, RouteOptions{Consumes: []string{"image/*"}}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+
+	cases := []struct {
+		method, path, body string
+		header             []string
+		code               int
+		acceptPost         string
+	}{
+		{"POST", "/users", `{}`, []string{"Content-Type", "application/json; charset=utf-8"}, http.StatusOK, ""},
+		{"POST", "/users", `{}`, []string{"Content-Type", "APPLICATION/JSON"}, http.StatusOK, ""},
+		{"POST", "/users", `a=b`, []string{"Content-Type", "application/x-www-form-urlencoded"}, http.StatusUnsupportedMediaType, "application/json, application/*+json"},
+		{"POST", "/users", `{}`, nil, http.StatusUnsupportedMediaType, "application/json, application/*+json"},
+		{"POST", "/users", "", nil, http.StatusOK, ""}, // no content to check
+		{"POST", "/users", `{}`, []string{"Content-Type", "application/json", "Accept", "text/html"}, http.StatusNotAcceptable, ""},
+		{"POST", "/users", `{}`, []string{"Content-Type", "application/json", "Accept", "text/html, */*;q=0.1"}, http.StatusOK, ""},
+		{"POST", "/users", `{}`, []string{"Content-Type", "application/json", "Accept", "application/*;q=0"}, http.StatusNotAcceptable, ""},
+		{"PATCH", "/users/1", `{}`, []string{"Content-Type", "application/json"}, http.StatusUnsupportedMediaType, ""},
+		{"PATCH", "/users/1", `{}`, []string{"Content-Type", "application/merge-patch+json"}, http.StatusOK, ""},
+		{"GET", "/report", "", []string{"Accept", "text/*"}, http.StatusOK, ""},
+		{"GET", "/report", "", []string{"Accept", "image/png"}, http.StatusNotAcceptable, ""},
+		{"PUT", "/upload", "png", []string{"Content-Type", "image/png"}, http.StatusOK, ""},
+		{"PUT", "/upload", "txt", []string{"Content-Type", "text/plain"}, http.StatusUnsupportedMediaType, ""},
+	}
+	fr, err := r.Freeze()
+	if err != nil {
+		t.Fatalf("freeze failed: %v", err)
+	}
+	for _, h := range []http.Handler{r, fr} {
+		for _, tc := range cases {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			for i := 0; i+1 < len(tc.header); i += 2 {
+				req.Header.Set(tc.header[i], tc.header[i+1])
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tc.code || w.Header().Get("Accept-Post") != tc.acceptPost {
+				t.Fatalf("%s %s %v: got %d (Accept-Post %q), want %d (%q)",
+					tc.method, tc.path, tc.header, w.Code, w.Header().Get("Accept-Post"), tc.code, tc.acceptPost)
+			}
+		}
+	}
+	req := httptest.NewRequest(http.MethodPatch, "/users/1", strings.NewReader("x"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Header().Get("Accept-Patch") != "application/merge-patch+json" {
+		t.Fatalf("expected Accept-Patch on 415, got %q", w.Header().Get("Accept-Patch"))
+	}
+
+	// Declared types show up in Routes, survive Replace and can be customized.
+	for _, info := range r.Routes() {
+		if info.Pattern == "/users" && (len(info.Consumes) != 2 || info.Consumes[1] != "application/*+json" || info.Produces[0] != "application/json") {
+			t.Fatalf("unexpected media types: %+v", info)
+		}
+	}
+	if err := r.Replace(http.MethodPut, "/upload", ok); err != nil {
+		t.Fatalf("replace failed: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodPut, "/upload", strings.NewReader("txt"))
+	req.Header.Set("Content-Type", "text/plain")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("replaced handler must keep Consumes, got %d", w.Code)
+	}
+
+	r2 := NewRouter()
+	r2.UnsupportedMediaType = func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "json only", http.StatusUnsupportedMediaType)
+	}
+	r2.NotAcceptable = func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "json only", http.StatusNotAcceptable)
+	}
+	if err := r2.HandleWith(http.MethodPost, "/", ok, RouteOptions{Consumes: []string{"application/json"}, Produces: []string{"application/json"}}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	for _, header := range [][2]string{{"Content-Type", "text/plain"}, {"Accept", "text/plain"}} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set(header[0], header[1])
+		w := httptest.NewRecorder()
+		r2.ServeHTTP(w, req)
+		if !strings.Contains(w.Body.String(), "json only") {
+			t.Fatalf("%s: expected custom response, got %d %q", header[0], w.Code, w.Body.String())
+		}
+	}
+
+	// OpenAPI documents bodies under the declared types.
+	if err := r2.HandleWith(http.MethodPut, "/", ok, RouteOptions{
+		Consumes: []string{"text/csv"},
+		Doc:      &RouteDoc{RequestBody: json.RawMessage(`{"type":"string"}`)},
+	}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	doc, err := OpenAPIJSON(r2.Routes(), OpenAPIConfig{Info: OpenAPIInfo{Title: "t", Version: "1"}})
+	if err != nil || !bytes.Contains(doc, []byte(`"text/csv"`)) || bytes.Contains(doc, []byte(`"application/json": {`)) {
+		t.Fatalf("expected text/csv request body, got %v\n%s", err, doc)
+	}
+
+	for _, bad := range []RouteOptions{{Consumes: []string{"json"}}, {Produces: []string{"text/html/x"}}, {Produces: []string{""}}} {
+		if err := r.HandleWith(http.MethodGet, "/bad", ok, bad); err == nil {
+			t.Fatalf("expected invalid media type error for %+v", bad)
+		}
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	cases := []struct {
+		accept []string
+		offers []string
+		want   string
+	}{
+		{nil, []string{"application/json", "text/html"}, "application/json"},
+		{nil, nil, ""},
+		{[]string{"text/html"}, []string{"application/json", "text/html"}, "text/html"},
+		{[]string{"text/*;q=0.8, application/json;q=0.5"}, []string{"application/json", "text/html"}, "text/html"},
+		{[]string{"*/*;q=0.1", "application/json"}, []string{"text/html", "application/json"}, "application/json"},
+		{[]string{"*/*"}, []string{"text/html", "application/json"}, "text/html"},
+		{[]string{"text/*, text/csv;q=0"}, []string{"text/csv"}, ""},
+		{[]string{"image/png"}, []string{"application/json"}, ""},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, v := range tc.accept {
+			req.Header.Add("Accept", v)
+		}
+		if got := NegotiateContentType(req, tc.offers...); got != tc.want {
+			t.Fatalf("Accept %q offers %v: got %q, want %q", tc.accept, tc.offers, got, tc.want)
+		}
+	}
+}
+
+func TestRouter_OptionsAndHeadPolicies(t *testing.T) {
+	build := func(configure func(r *Router)) (*Router, *FrozenRouter) {
+		r := NewRouter()
+		configure(r)
+		mustGET(t, r, "/users", func(w http.ResponseWriter, _ *http.Request) { _, _ = io.WriteString(w, "hello") })
+		mustGET(t, r, "/items/:id", func(w http.ResponseWriter, _ *http.Request) {
+			id, _ := Param(w, "id")
+			_, _ = io.Copy(w, strings.NewReader("item "+id))
+		})
+		mustGET(t, r, "/sized", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Length", "42")
+		})
+		mustGET(t, r, "/created", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = io.WriteString(w, "new")
+		})
+		mustGET(t, r, "/empty", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+		mustGET(t, r, "/stream", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = io.WriteString(w, "a")
+			w.(http.Flusher).Flush()
+			_, _ = io.WriteString(w, "b")
+		})
+		if err := r.HEAD("/explicit", func(w http.ResponseWriter, _ *http.Request) { _, _ = io.WriteString(w, "head") }); err != nil {
+			t.Fatalf("HEAD route failed: %v", err)
+		}
+		if err := r.OPTIONS("/cors", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusTeapot) }); err != nil {
+			t.Fatalf("OPTIONS route failed: %v", err)
+		}
+		mustGET(t, r, "/cors", func(w http.ResponseWriter, _ *http.Request) {})
+		fr, err := r.Freeze()
+		if err != nil {
+			t.Fatalf("freeze failed: %v", err)
+		}
+		return r, fr
+	}
+	serve := func(h http.Handler, method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	// GlobalOPTIONS answers automatic OPTIONS with Allow already set.
+	r, fr := build(func(r *Router) {
+		r.GlobalOPTIONS = func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Access-Control-Allow-Methods", w.Header().Get("Allow"))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	for _, h := range []http.Handler{r, fr} {
+		w := serve(h, http.MethodOptions, "/items/1")
+		if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Methods") != "GET, HEAD, OPTIONS" {
+			t.Fatalf("expected preflight from GlobalOPTIONS, got %d %v", w.Code, w.Header())
+		}
+		if w := serve(h, http.MethodOptions, "/cors"); w.Code != http.StatusTeapot {
+			t.Fatalf("explicit OPTIONS route must win, got %d", w.Code)
+		}
+		if w := serve(h, http.MethodHead, "/users"); w.Body.String() != "hello" {
+			t.Fatalf("HEAD fallback writes through by default, got %q", w.Body.String())
+		}
+	}
+
+	// DisableAutoOPTIONS answers 405 and lists only registered methods.
+	r, fr = build(func(r *Router) { r.DisableAutoOPTIONS = true })
+	for _, h := range []http.Handler{r, fr} {
+		for _, path := range []string{"/users", "/items/1"} {
+			w := serve(h, http.MethodOptions, path)
+			if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+				t.Fatalf("OPTIONS %s: expected 405 with Allow \"GET, HEAD\", got %d %q", path, w.Code, w.Header().Get("Allow"))
+			}
+		}
+		if w := serve(h, http.MethodDelete, "/cors"); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+			t.Fatalf("expected registered OPTIONS in Allow, got %d %q", w.Code, w.Header().Get("Allow"))
+		}
+	}
+
+	// SuppressHeadBody discards bodies of GET handlers serving HEAD and sets Content-Length.
+	r, fr = build(func(r *Router) { r.SuppressHeadBody = true })
+	for _, h := range []http.Handler{r, fr} {
+		for _, tc := range []struct {
+			path, length string
+			code         int
+		}{
+			{"/users", "5", http.StatusOK},
+			{"/items/7", "6", http.StatusOK},
+			{"/sized", "42", http.StatusOK},
+			{"/created", "3", http.StatusCreated},
+			{"/empty", "", http.StatusNoContent},
+			{"/stream", "", http.StatusOK},
+		} {
+			for i := 0; i < 2; i++ { // pooled writers are reset
+				w := serve(h, http.MethodHead, tc.path)
+				if w.Code != tc.code || w.Body.Len() != 0 || w.Header().Get("Content-Length") != tc.length {
+					t.Fatalf("HEAD %s: got %d body %q Content-Length %q, want %d %q",
+						tc.path, w.Code, w.Body.String(), w.Header().Get("Content-Length"), tc.code, tc.length)
+				}
+			}
+		}
+		if w := serve(h, http.MethodHead, "/explicit"); w.Body.String() != "head" {
+			t.Fatalf("explicit HEAD route must not be wrapped, got %q", w.Body.String())
+		}
+		if w := serve(h, http.MethodGet, "/users"); w.Body.String() != "hello" || w.Header().Get("Content-Length") != "" {
+			t.Fatalf("GET must be unaffected, got %q %v", w.Body.String(), w.Header())
+		}
+	}
+}
+
+func TestRouter_NormalizePolicy(t *testing.T) {
+	build := func(policy NormalizePolicy) (*Router, *FrozenRouter) {
+		r := NewRouter()
+		r.IgnoreCase = true
+		r.StrictSlash = true
+		r.Normalize = policy
+		echo := func(w http.ResponseWriter, req *http.Request) {
+			_, _ = fmt.Fprintf(w, "%s %s %d", req.Method, req.URL.Path, Normalized(w))
+		}
+		mustGET(t, r, "/users", echo)
+		mustGET(t, r, "/dir/", echo)
+		if err := r.POST("/users", echo); err != nil {
+			t.Fatalf("POST route failed: %v", err)
+		}
+		fr, err := r.Freeze()
+		if err != nil {
+			t.Fatalf("freeze failed: %v", err)
+		}
+		return r, fr
+	}
+	serve := func(h http.Handler, method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	type check struct {
+		method, path string
+		code         int
+		body         string // or Location for redirects
+	}
+	run := func(name string, policy NormalizePolicy, checks []check) {
+		r, fr := build(policy)
+		for _, h := range []http.Handler{r, fr} {
+			for _, c := range checks {
+				for i := 0; i < 2; i++ { // pooled writers are reset
+					w := serve(h, c.method, c.path)
+					got := w.Body.String()
+					if w.Code/100 == 3 {
+						got = w.Header().Get("Location")
+					} else if w.Code == http.StatusNotFound || w.Code == http.StatusMethodNotAllowed {
+						got = w.Header().Get("Allow")
+					}
+					if w.Code != c.code || got != c.body {
+						t.Fatalf("%s: %s %s got %d %q, want %d %q", name, c.method, c.path, w.Code, got, c.code, c.body)
+					}
+				}
+			}
+		}
+	}
+
+	run("default", NormalizePolicy{}, []check{
+		{http.MethodGet, "/a/../users", http.StatusMovedPermanently, "/users"},
+		{http.MethodPost, "//users", http.StatusPermanentRedirect, "/users"},
+		{http.MethodGet, "/users/", http.StatusMovedPermanently, "/users"},
+		{http.MethodGet, "/USERS", http.StatusOK, "GET /USERS 0"},
+	})
+	rewritten := fmt.Sprint(NormalizedClean | NormalizedCase)
+	run("rewrite", NormalizePolicy{Clean: NormalizeRewrite, TrailingSlash: NormalizeRewrite, Case: NormalizeRewrite}, []check{
+		{http.MethodGet, "/users", http.StatusOK, "GET /users 0"},
+		{http.MethodPost, "//Users", http.StatusOK, "POST /users " + rewritten},
+		{http.MethodPost, "/users/", http.StatusOK, "POST /users " + fmt.Sprint(NormalizedTrailingSlash)},
+		{http.MethodGet, "/DIR", http.StatusOK, "GET /dir/ " + fmt.Sprint(NormalizedCase|NormalizedTrailingSlash)},
+		{http.MethodDelete, "/users/", http.StatusMethodNotAllowed, "GET, HEAD, POST, OPTIONS"},
+	})
+	run("reject", NormalizePolicy{Clean: NormalizeReject, TrailingSlash: NormalizeReject, Case: NormalizeReject}, []check{
+		{http.MethodGet, "/users", http.StatusOK, "GET /users 0"},
+		{http.MethodGet, "/./users", http.StatusBadRequest, ""},
+		{http.MethodGet, "/Users", http.StatusBadRequest, ""},
+		{http.MethodPost, "/users/", http.StatusBadRequest, ""},
+	})
+	run("pass-through", NormalizePolicy{Clean: NormalizePassThrough, TrailingSlash: NormalizePassThrough, Case: NormalizePassThrough}, []check{
+		{http.MethodGet, "//users", http.StatusNotFound, ""},
+		{http.MethodGet, "/users/", http.StatusNotFound, ""},
+		{http.MethodGet, "/Users", http.StatusOK, "GET /Users 0"},
+	})
+	run("redirect", NormalizePolicy{Case: NormalizeRedirect}, []check{
+		{http.MethodGet, "/USERS?q=1", http.StatusMovedPermanently, "/users?q=1"},
+		{http.MethodGet, "//a/../USERS", http.StatusMovedPermanently, "/users"}, // one hop
+		{http.MethodGet, "/users", http.StatusOK, "GET /users 0"},
+	})
+
+	// The policy travels with manifests.
+	_, fr := build(NormalizePolicy{Clean: NormalizeRewrite, Case: NormalizeReject})
+	data, err := fr.ExportManifest()
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"clean": "rewrite"`) {
+		t.Fatalf("expected named modes in manifest, got %s", data)
+	}
+	loaded, err := LoadManifest(data, map[string]HandleFunc{
+		"GET /users": func(http.ResponseWriter, *http.Request) {}, "GET /dir/": func(http.ResponseWriter, *http.Request) {},
+		"POST /users": func(http.ResponseWriter, *http.Request) {},
+	})
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if loaded.Normalize != fr.Normalize {
+		t.Fatalf("expected policy %+v, got %+v", fr.Normalize, loaded.Normalize)
+	}
+	var mode NormalizeMode
+	if err := mode.UnmarshalText([]byte("sideways")); err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+}
+
+func TestRouter_CanonicalCase(t *testing.T) {
+	build := func(policy NormalizePolicy) (*Router, *FrozenRouter) {
+		r := NewRouter()
+		r.IgnoreCase = true
+		r.Normalize = policy
+		echo := func(w http.ResponseWriter, req *http.Request) {
+			id, _ := Param(w, "id")
+			_, _ = fmt.Fprintf(w, "%s %s %d", req.URL.Path, id, Normalized(w))
+		}
+		mustGET(t, r, "/Users/:id", echo)
+		mustGET(t, r, "/API/v1/*path", echo)
+		mustGET(t, r, "/Docs/:page?", echo)
+		fr, err := r.Freeze()
+		if err != nil {
+			t.Fatalf("freeze failed: %v", err)
+		}
+		return r, fr
+	}
+	type check struct {
+		method, path string
+		code         int
+		want         string // Location for redirects, else body
+	}
+	run := func(name string, policy NormalizePolicy, checks []check) {
+		r, fr := build(policy)
+		for _, h := range []http.Handler{r, fr} {
+			for _, c := range checks {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+				got := w.Body.String()
+				if w.Code/100 == 3 {
+					got = w.Header().Get("Location")
+				}
+				if w.Code != c.code || (c.want != "" && got != c.want) {
+					t.Fatalf("%s: %s %s got %d %q, want %d %q", name, c.method, c.path, w.Code, got, c.code, c.want)
+				}
+			}
+		}
+	}
+
+	run("redirect", NormalizePolicy{Case: NormalizeRedirect, CanonicalCase: true}, []check{
+		{http.MethodGet, "/Users/AbC", http.StatusOK, "/Users/AbC AbC 0"},
+		{http.MethodGet, "/users/AbC", http.StatusMovedPermanently, "/Users/AbC"},
+		{http.MethodGet, "/USERS/AbC?x=1", http.StatusMovedPermanently, "/Users/AbC?x=1"},
+		{http.MethodHead, "/users/x", http.StatusMovedPermanently, "/Users/x"},
+		{http.MethodGet, "/api/V1/Some/Path", http.StatusMovedPermanently, "/API/v1/Some/Path"},
+		{http.MethodGet, "/docs", http.StatusMovedPermanently, "/Docs"},
+		{http.MethodGet, "/DOCS/Intro", http.StatusMovedPermanently, "/Docs/Intro"},
+		{http.MethodGet, "/nothing", http.StatusNotFound, ""},
+		{http.MethodPost, "/users/1", http.StatusMethodNotAllowed, ""},
+	})
+	run("rewrite", NormalizePolicy{Case: NormalizeRewrite, CanonicalCase: true}, []check{
+		{http.MethodGet, "/users/AbC", http.StatusOK, "/Users/AbC AbC " + fmt.Sprint(NormalizedCase)},
+		{http.MethodGet, "/Users/AbC", http.StatusOK, "/Users/AbC AbC 0"},
+	})
+	run("reject", NormalizePolicy{Case: NormalizeReject, CanonicalCase: true}, []check{
+		{http.MethodGet, "/users/AbC", http.StatusBadRequest, ""},
+		{http.MethodGet, "/Users/AbC", http.StatusOK, "/Users/AbC AbC 0"},
+	})
+	// Without CanonicalCase the static segments are lower-cased; param values are kept.
+	run("lower-case", NormalizePolicy{Case: NormalizeRedirect}, []check{
+		{http.MethodGet, "/Users/AbC", http.StatusMovedPermanently, "/users/AbC"},
+		{http.MethodGet, "//USERS/AbCdEf", http.StatusMovedPermanently, "/users/AbCdEf"}, // one hop
+		{http.MethodGet, "/users/AbCdEf", http.StatusOK, "/users/AbCdEf AbCdEf 0"},
+		{http.MethodGet, "/API/v1/Some/Path", http.StatusMovedPermanently, "/api/v1/Some/Path"},
+		{http.MethodGet, "/NOTHING", http.StatusNotFound, ""},
+	})
+	run("lower-case rewrite", NormalizePolicy{Case: NormalizeRewrite}, []check{
+		{http.MethodGet, "/U/AbCdEf", http.StatusNotFound, ""},
+		{http.MethodGet, "/USERS/AbCdEf", http.StatusOK, "/users/AbCdEf AbCdEf " + fmt.Sprint(NormalizedCase)},
+	})
+	run("lower-case reject", NormalizePolicy{Case: NormalizeReject}, []check{
+		{http.MethodGet, "/Users/AbC", http.StatusBadRequest, ""},
+		{http.MethodGet, "/users/AbC", http.StatusOK, "/users/AbC AbC 0"},
+	})
+	run("pass-through", NormalizePolicy{CanonicalCase: true}, []check{
+		{http.MethodGet, "/users/AbC", http.StatusOK, "/users/AbC AbC 0"},
+	})
+}
+
+func TestParamAccessors(t *testing.T) {
+	r := NewRouter()
+	var got []string
+	var errs []error
+	mustGET(t, r, "/v/:int/:uint/:flag/:id/:day", func(w http.ResponseWriter, _ *http.Request) {
+		got, errs = got[:0], errs[:0]
+		n, err := ParamInt(w, "int")
+		got, errs = append(got, fmt.Sprint(n)), append(errs, err)
+		n64, err := ParamInt64(w, "int")
+		got, errs = append(got, fmt.Sprint(n64)), append(errs, err)
+		u, err := ParamUint(w, "uint")
+		got, errs = append(got, fmt.Sprint(u)), append(errs, err)
+		b, err := ParamBool(w, "flag")
+		got, errs = append(got, fmt.Sprint(b)), append(errs, err)
+		id, err := ParamUUID(w, "id")
+		got, errs = append(got, id), append(errs, err)
+		day, err := ParamTime(w, "day", time.DateOnly)
+		got, errs = append(got, day.Format(time.DateOnly)), append(errs, err)
+		_, err = ParamInt(w, "nope")
+		errs = append(errs, err)
+		for k, v := range ParamsFrom(w) {
+			got = append(got, k+"="+v)
+		}
+	})
+	if err := r.Host(":tenant.example.com").GET("/h/:id", func(w http.ResponseWriter, _ *http.Request) {
+		got = got[:0]
+		for k, v := range ParamsFrom(w) {
+			got = append(got, k+"="+v)
+			break // early stop is honored
+		}
+		for k, v := range ParamsFrom(w) {
+			got = append(got, k+"="+v)
+		}
+	}); err != nil {
+		t.Fatalf("host route failed: %v", err)
+	}
+	serve := func(host, path string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if host != "" {
+			req.Host = host
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	serve("", "/v/-42/7/true/6BA7B810-9DAD-11D1-80B4-00C04FD430C8/2026-10-16")
+	want := []string{"-42", "-42", "7", "true", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "2026-10-16",
+		"int=-42", "uint=7", "flag=true", "id=6BA7B810-9DAD-11D1-80B4-00C04FD430C8", "day=2026-10-16"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	for _, err := range errs[:6] {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	var pe *ParamError
+	if !errors.As(errs[6], &pe) || pe.Name != "nope" || !errors.Is(errs[6], ErrParamMissing) {
+		t.Fatalf("expected missing ParamError, got %v", errs[6])
+	}
+
+	serve("", "/v/x/-1/maybe/not-a-uuid/16.10.2026")
+	for i, typ := range []string{"int", "int64", "uint", "bool", "uuid", "time"} {
+		if !errors.As(errs[i], &pe) || pe.Type != typ || pe.Value == "" {
+			t.Fatalf("expected %s ParamError, got %v", typ, errs[i])
+		}
+	}
+	if !errors.Is(errs[0], strconv.ErrSyntax) || errs[0].Error() != `invalid int param 'int': "x": invalid syntax` {
+		t.Fatalf("unexpected int error: %v", errs[0])
+	}
+	serve("", "/v/99999999999999999999/1/1/6ba7b810-9dad-11d1-80b4-00c04fd430c8/2026-10-16")
+	if !errors.Is(errs[0], strconv.ErrRange) {
+		t.Fatalf("expected range error, got %v", errs[0])
+	}
+
+	serve("acme.example.com", "/h/9")
+	if want := []string{"id=9", "id=9", "tenant=acme"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected route then host params %q, got %q", want, got)
+	}
+	for k := range ParamsFrom(httptest.NewRecorder()) {
+		t.Fatalf("expected no params outside a route, got %s", k)
+	}
+}
+
+func TestRouter_RouteMiddlewares(t *testing.T) {
+	var trace []string
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				route, _ := MatchedRoute(w)
+				trace = append(trace, name+":"+route.Pattern)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+	handler := func(name string) HandleFunc {
+		return func(w http.ResponseWriter, _ *http.Request) { trace = append(trace, name) }
+	}
+
+	r := NewRouter()
+	if err := r.Use(tag("global")); err != nil {
+		t.Fatalf("use failed: %v", err)
+	}
+	api := r.Group("/api", tag("group"))
+	if err := api.HandleWith(http.MethodPost, "/upload/:id", handler("upload"), RouteOptions{
+		Middlewares: []Middleware{tag("route1"), tag("route2")},
+	}); err != nil {
+		t.Fatalf("route failed: %v", err)
+	}
+	if err := api.GET("/plain", handler("plain")); err != nil {
+		t.Fatalf("route failed: %v", err)
+	}
+	if err := r.With(tag("with")).PUT("/files/:name", handler("files")); err != nil {
+		t.Fatalf("With route failed: %v", err)
+	}
+	if err := api.With(tag("gwith")).DELETE("/items/:id", handler("items")); err != nil {
+		t.Fatalf("group With route failed: %v", err)
+	}
+	if err := r.HandleWith(http.MethodGet, "/bad", handler("bad"), RouteOptions{Middlewares: []Middleware{nil}}); err == nil {
+		t.Fatal("expected error for nil route middleware")
+	}
+
+	serve := func(method, path string) []string {
+		trace = nil
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+		return trace
+	}
+	for _, tc := range []struct {
+		method, path string
+		want         []string
+	}{
+		{http.MethodPost, "/api/upload/1", []string{
+			"global:/api/upload/:id", "group:/api/upload/:id", "route1:/api/upload/:id", "route2:/api/upload/:id", "upload"}},
+		{http.MethodGet, "/api/plain", []string{"global:/api/plain", "group:/api/plain", "plain"}},
+		{http.MethodPut, "/files/a.txt", []string{"global:/files/:name", "with:/files/:name", "files"}},
+		{http.MethodDelete, "/api/items/2", []string{"global:/api/items/:id", "group:/api/items/:id", "gwith:/api/items/:id", "items"}},
+	} {
+		if got := serve(tc.method, tc.path); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s %s: expected %q, got %q", tc.method, tc.path, tc.want, got)
+		}
+	}
+
+	// With does not leak into the group it was derived from.
+	if err := api.GET("/after", handler("after")); err != nil {
+		t.Fatalf("route failed: %v", err)
+	}
+	if got := serve(http.MethodGet, "/api/after"); len(got) != 3 {
+		t.Fatalf("expected global and group middlewares only, got %q", got)
+	}
+
+	// Replace keeps the middlewares declared by the route.
+	if err := api.Replace(http.MethodPost, "/upload/:id", handler("upload2")); err != nil {
+		t.Fatalf("replace failed: %v", err)
+	}
+	want := []string{"global:/api/upload/:id", "group:/api/upload/:id", "route1:/api/upload/:id", "route2:/api/upload/:id", "upload2"}
+	if got := serve(http.MethodPost, "/api/upload/1"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after replace: expected %q, got %q", want, got)
+	}
+
+	fr, err := r.Freeze()
+	if err != nil {
+		t.Fatalf("freeze failed: %v", err)
+	}
+	trace = nil
+	fr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/upload/1", nil))
+	if !reflect.DeepEqual(trace, want) {
+		t.Fatalf("frozen: expected %q, got %q", want, trace)
+	}
+}
+
+func TestRouter_ColonSegments(t *testing.T) {
+	r := NewRouter()
+	echo := func(name string) HandleFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			v, _ := Param(w, name)
+			w.Write([]byte(name + "=" + v))
+		}
+	}
+	// Whole-segment params keep their original names.
+	mustGET(t, r, "/users/:user-id", echo("user-id"))
+	mustGET(t, r, "/files/:name.zip", echo("name.zip"))
+	mustGET(t, r, "/v1/things:search", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("search"))
+	})
+	if err := r.GET("/bad/:id<int>x", echo("id")); err == nil {
+		t.Fatalf("expected a constraint not ending the segment to be rejected")
+	}
+	if err := r.GET("/bad/:{user-id}.zip", echo("user-id")); err == nil {
+		t.Fatalf("expected an invalid name inside :{...} to be rejected")
+	}
+	kinds := make(map[string]RouteKind)
+	for _, info := range r.Routes() {
+		kinds[info.Pattern] = info.Kind
+	}
+	if kinds["/v1/things:search"] != RouteStatic || kinds["/users/:user-id"] != RouteParam {
+		t.Fatalf("unexpected route kinds %v", kinds)
+	}
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{path: "/v1/things:search", code: http.StatusOK, body: "search"},
+		{path: "/v1/thingsXsearch", code: http.StatusNotFound},
+		{path: "/v1/things:other", code: http.StatusNotFound},
+		{path: "/users/42", code: http.StatusOK, body: "user-id=42"},
+		{path: "/files/report.zip", code: http.StatusOK, body: "name.zip=report.zip"},
+		{path: "/files/report.tar", code: http.StatusOK, body: "name.zip=report.tar"},
+	}
+	for name, h := range map[string]http.Handler{"router": r, "frozen": mustFreeze(t, r)} {
+		for _, tc := range cases {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != tc.code || (tc.body != "" && rec.Body.String() != tc.body) {
+				t.Fatalf("%s %s: expected %d %q, got %d %q", name, tc.path, tc.code, tc.body, rec.Code, rec.Body.String())
+			}
+		}
+	}
+}
+
+func TestRouter_StrictParamSlash(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		r := NewRouter()
+		r.StrictParamSlash = strict
+		mustGET(t, r, "/users/:id", func(w http.ResponseWriter, req *http.Request) {
+			id, _ := Param(w, "id")
+			w.Write([]byte(id))
+		})
+		mustGET(t, r, "/files/*path", func(w http.ResponseWriter, req *http.Request) {})
+		for name, h := range map[string]http.Handler{"router": r, "frozen": mustFreeze(t, r)} {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/42/", nil))
+			if strict {
+				if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/users/42" {
+					t.Fatalf("%s strict: expected redirect to /users/42, got %d %q", name, rec.Code, rec.Header().Get("Location"))
+				}
+			} else if rec.Code != http.StatusOK || rec.Body.String() != "42" {
+				t.Fatalf("%s: expected /users/42/ to be served, got %d %q", name, rec.Code, rec.Body.String())
+			}
+
+			rec = httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/users/42", nil))
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("%s strict=%t: expected 405, got %d", name, strict, rec.Code)
+			}
+
+			rec = httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/files/a/", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("%s strict=%t: expected wildcard to serve the slash form, got %d", name, strict, rec.Code)
+			}
+		}
+	}
+}
+
+func TestRouter_ServeMuxSubtreeHidden(t *testing.T) {
+	r := NewRouter()
+	reply := func(w http.ResponseWriter, req *http.Request) {
+		var keys []string
+		for k, v := range ParamsFrom(w) {
+			keys = append(keys, k+"="+v)
+		}
+		fmt.Fprintf(w, "%s|%s|%s", strings.Join(keys, ","), req.PathValue("id"), req.PathValue(muxSubtree))
+	}
+	if err := r.HandleFunc("GET /static/", reply); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := r.HandleFunc("GET /users/{id}", reply); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	// Handle keeps the trie meaning of a trailing slash: exact, not a subtree.
+	if err := r.Handle(http.MethodGet, "/docs/{id}/", reply); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	for _, info := range r.Routes() {
+		for _, p := range info.Params {
+			if p == muxSubtree {
+				t.Fatalf("%s exposes the subtree param: %v", info.Pattern, info.Params)
+			}
+		}
+	}
+	doc, err := OpenAPIJSON(r.Routes(), OpenAPIConfig{})
+	if err != nil || strings.Contains(string(doc), muxSubtree) || !strings.Contains(string(doc), `"/static/"`) {
+		t.Fatalf("unexpected OpenAPI document: %s, %v", doc, err)
+	}
+
+	fr := mustFreeze(t, r)
+	data, err := fr.ExportManifest()
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if strings.Count(string(data), `"pathValue": true`) != 3 {
+		t.Fatalf("expected every ServeMux route flagged in manifest: %s", data)
+	}
+	loaded, err := LoadManifest(data, map[string]HandleFunc{
+		"GET /static/*...": reply,
+		"GET /users/:id":   reply,
+		"GET /docs/:id/":   reply,
+	})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/static/css/site.css", http.StatusOK, "||"},
+		{"/users/7", http.StatusOK, "id=7|7|"},
+		{"/docs/3/", http.StatusOK, "id=3|3|"},
+		{"/docs/3/x", http.StatusNotFound, ""},
+	}
+	for name, h := range map[string]http.Handler{"router": r, "frozen": fr, "manifest": loaded} {
+		for _, tc := range cases {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != tc.code || (tc.body != "" && rec.Body.String() != tc.body) {
+				t.Fatalf("%s %s: expected %d %q, got %d %q", name, tc.path, tc.code, tc.body, rec.Code, rec.Body.String())
+			}
+		}
+	}
+}
+
+// TestRouter_SuppressHeadBodyPanic checks that the HEAD writer is released when the GET
+// handler panics, and that it passes Hijack and Push through.
+func TestRouter_SuppressHeadBodyPanic(t *testing.T) {
+	r := NewRouter()
+	r.SuppressHeadBody = true
+	r.PanicHandler = func(w http.ResponseWriter, _ *http.Request, _ any) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	mustGET(t, r, "/early", func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+	mustGET(t, r, "/late", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+		panic("boom")
+	})
+	mustGET(t, r, "/conn", func(w http.ResponseWriter, _ *http.Request) {
+		if err := w.(http.Pusher).Push("/app.js", nil); err != nil {
+			t.Errorf("push failed: %v", err)
+		}
+		if _, _, err := w.(http.Hijacker).Hijack(); err != nil {
+			t.Errorf("hijack failed: %v", err)
+		}
+	})
+	fr, err := r.Freeze()
+	if err != nil {
+		t.Fatalf("freeze failed: %v", err)
+	}
+
+	for _, h := range []http.Handler{r, fr} {
+		for i := 0; i < 2; i++ { // pooled writers are reset
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/early", nil))
+			if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Length") != "" {
+				t.Fatalf("panic before writing: got %d Content-Length %q, want 500 from the PanicHandler",
+					w.Code, w.Header().Get("Content-Length"))
+			}
+			w = httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/late", nil))
+			if w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("Content-Length") != "5" {
+				t.Fatalf("panic after writing: got %d body %q Content-Length %q, want 200 \"5\"",
+					w.Code, w.Body.String(), w.Header().Get("Content-Length"))
+			}
+		}
+
+		base := &passthroughRW{}
+		h.ServeHTTP(base, httptest.NewRequest(http.MethodHead, "/conn", nil))
+		if !base.hijacked || base.pushedPath != "/app.js" {
+			t.Fatalf("expected Hijack and Push to be forwarded, got hijacked=%v pushed=%q", base.hijacked, base.pushedPath)
+		}
+		if base.Header().Get("Content-Length") != "" {
+			t.Fatalf("hijacked HEAD response must not get a Content-Length, got %q", base.Header().Get("Content-Length"))
+		}
+	}
+}
+
+// TestRouter_PassThroughDotSegments checks that Clean pass-through still rejects "."
+// and ".." segments, and otherwise hands the path over as sent.
+func TestRouter_PassThroughDotSegments(t *testing.T) {
+	r := NewRouter()
+	r.Normalize = NormalizePolicy{Clean: NormalizePassThrough}
+	echo := func(w http.ResponseWriter, req *http.Request) {
+		_, _ = fmt.Fprint(w, req.URL.Path)
+	}
+	mustGET(t, r, "/files/*path", echo)
+	mustGET(t, r, "/u/:id", echo)
+	mustGET(t, r, "/users", echo)
+	fr, err := r.Freeze()
+	if err != nil {
+		t.Fatalf("freeze failed: %v", err)
+	}
+
+	for _, h := range []http.Handler{r, fr} {
+		for _, path := range []string{"/files/../../etc/passwd", "/u/..", "/u/.", "/./users", "/files/a/./b"} {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("GET %s: got %d, want 400", path, w.Code)
+			}
+		}
+		// Not dot segments, and "//" is not cleaned: the handler sees the path as sent.
+		for _, path := range []string{"/u/..x", "/files/.well-known/a", "/files/a//b"} {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != http.StatusOK || w.Body.String() != path {
+				t.Fatalf("GET %s: got %d %q, want 200 with the path as sent", path, w.Code, w.Body.String())
+			}
+		}
+	}
+}
+
+// TestRouter_OptionalConstraintQuantifier checks that a '?' inside a constraint is not
+// taken for the optional marker.
+func TestRouter_OptionalConstraintQuantifier(t *testing.T) {
+	r := NewRouter()
+	reply := func(w http.ResponseWriter, req *http.Request) {
+		l, ok := Param(w, "l")
+		fmt.Fprintf(w, "l=%s(%t)", l, ok)
+	}
+	mustGET(t, r, "/lang/:l<en(-us)?>", reply)
+	mustGET(t, r, "/docs/:l<v1(-beta)?>?", reply)
+	if err := r.GET("/bad/:a?/:l<en(-us)?>", reply); err == nil {
+		t.Fatalf("expected a '?' outside a constraint in the middle to be rejected")
+	}
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{path: "/lang/en", code: http.StatusOK, body: "l=en(true)"},
+		{path: "/lang/en-us", code: http.StatusOK, body: "l=en-us(true)"},
+		{path: "/lang/fr", code: http.StatusNotFound},
+		{path: "/docs", code: http.StatusOK, body: "l=(false)"},
+		{path: "/docs/v1-beta", code: http.StatusOK, body: "l=v1-beta(true)"},
+		{path: "/docs/v2", code: http.StatusNotFound},
+	}
+	for name, h := range map[string]http.Handler{"router": r, "frozen": mustFreeze(t, r)} {
+		for _, tc := range cases {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != tc.code || (tc.body != "" && rec.Body.String() != tc.body) {
+				t.Fatalf("%s %s: expected %d %q, got %d %q", name, tc.path, tc.code, tc.body, rec.Code, rec.Body.String())
+			}
+		}
+	}
+}
+
+// TestRouter_ReplaceGroupAndMount checks that Replace keeps the group chain of a route
+// wherever it is called from, and that Remove handles mounts.
+func TestRouter_ReplaceGroupAndMount(t *testing.T) {
+	r := NewRouter()
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Add("X-Chain", name)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+	reply := func(body string) HandleFunc {
+		return func(w http.ResponseWriter, req *http.Request) { _, _ = w.Write([]byte(body)) }
+	}
+	api := r.Group("/api", tag("api"))
+	if err := api.GET("/users", reply("v1")); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := r.With(tag("with")).POST("/upload", reply("v1")); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := api.Mount("/files", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("files" + req.URL.Path))
+	})); err != nil {
+		t.Fatalf("mount: %v", err)
+	}
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	if err := r.Replace(http.MethodGet, "/api/users", reply("v2")); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if err := r.Replace(http.MethodPost, "/upload", reply("v2")); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if err := api.Replace(http.MethodGet, "/users", reply("v3")); err != nil {
+		t.Fatalf("group replace: %v", err)
+	}
+	for _, tc := range []struct{ method, path, body, chain string }{
+		{http.MethodGet, "/api/users", "v3", "api"},
+		{http.MethodPost, "/upload", "v2", "with"},
+	} {
+		rec := serve(tc.method, tc.path)
+		if rec.Body.String() != tc.body || strings.Join(rec.Header()["X-Chain"], ",") != tc.chain {
+			t.Fatalf("%s %s: got %q with chain %v, want %q with %q", tc.method, tc.path, rec.Body.String(), rec.Header()["X-Chain"], tc.body, tc.chain)
+		}
+	}
+
+	if err := r.Replace(MethodAny, "/api/files", reply("x")); err == nil || !strings.Contains(err.Error(), "cannot replace mount") {
+		t.Fatalf("expected mount replace to be rejected, got %v", err)
+	}
+	if rec := serve(http.MethodGet, "/api/files/a.txt"); rec.Body.String() != "files/a.txt" {
+		t.Fatalf("expected mounted handler, got %q", rec.Body.String())
+	}
+	if err := api.Remove(MethodAny, "/files"); err != nil {
+		t.Fatalf("remove mount: %v", err)
+	}
+	if rec := serve(http.MethodGet, "/api/files/a.txt"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after removing the mount, got %d", rec.Code)
+	}
+	if err := r.Remove(MethodAny, "/api/files"); err == nil {
+		t.Fatalf("expected second remove to fail")
+	}
+	if got := len(r.Routes()); got != 2 || r.routesCount != 2 {
+		t.Fatalf("expected 2 routes left, got %d (count %d)", got, r.routesCount)
+	}
+}
+
+// TestFrozenRouter_RandomParity registers random route sets mixing mixed segments,
+// constrained params and wildcards, and checks that Router and both FrozenRouter
+// matchers serve the same route with the same params (ParamsFrom included, so a param
+// left over from a failed alternative shows up).
+func TestFrozenRouter_RandomParity(t *testing.T) {
+	kinds := []func(i int) string{
+		func(int) string { return "users" },
+		func(int) string { return "a" },
+		func(i int) string { return fmt.Sprintf(":p%d", i) },
+		func(i int) string { return fmt.Sprintf(":n%d<int>", i) },
+		func(i int) string { return fmt.Sprintf(":{f%d}.zip", i) },
+		func(i int) string { return fmt.Sprintf("u:{x%d}", i) },
+		func(i int) string { return fmt.Sprintf(":{l%d}-:{r%d}", i, i) },
+	}
+	segments := []string{"users", "a", "u", "us", "x.zip", "users.zip", "a-b", "12", "u-1", "zip"}
+	rnd := rand.New(rand.NewSource(7))
+
+	for trial := 0; trial < 150; trial++ {
+		r := NewRouter()
+		var patterns []string
+		if trial == 0 {
+			patterns = []string{"/A/users/:c/:d<int>", "/A/:{fb}.zip/:{fc}.zip/*rd", "/A/*rest"}
+		}
+		for n := 3 + rnd.Intn(6); n > 0; n-- {
+			var b strings.Builder
+			b.WriteString("/A")
+			depth := 1 + rnd.Intn(3)
+			for i := 0; i < depth; i++ {
+				b.WriteString("/" + kinds[rnd.Intn(len(kinds))](i))
+			}
+			if rnd.Intn(3) == 0 {
+				b.WriteString(fmt.Sprintf("/*w%d", depth))
+			}
+			patterns = append(patterns, b.String())
+		}
+		for _, p := range patterns {
+			pattern := p
+			_ = r.GET(pattern, func(w http.ResponseWriter, req *http.Request) {
+				_, _ = io.WriteString(w, pattern)
+				for k, v := range ParamsFrom(w) {
+					_, _ = io.WriteString(w, " "+k+"="+v)
+				}
+			})
+		}
+		tree := mustFreeze(t, r)
+		flat, err := r.FreezeWith(FreezeOptions{Matcher: MatcherFlat})
+		if err != nil {
+			t.Fatalf("FreezeWith: %v", err)
+		}
+
+		for probe := 0; probe < 60; probe++ {
+			var b strings.Builder
+			b.WriteString("/A")
+			for n := rnd.Intn(5); n > 0; n-- {
+				b.WriteString("/" + segments[rnd.Intn(len(segments))])
+			}
+			path := b.String()
+			if trial == 0 && probe == 0 {
+				path = "/A/users"
+			}
+			var bodies [3]string
+			for i, h := range []http.Handler{r, tree, flat} {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				bodies[i] = fmt.Sprintf("%d %s", w.Code, w.Body.String())
+			}
+			if bodies[0] != bodies[1] || bodies[0] != bodies[2] {
+				t.Fatalf("GET %s with %q:\nrouter %q\ntree   %q\nflat   %q", path, patterns, bodies[0], bodies[1], bodies[2])
+			}
+		}
+	}
+}
+
 // nopRW for Zero-Alloc Benchmark
 type nopRW struct {
 	header http.Header
@@ -1610,3 +4306,195 @@ func BenchmarkFrozen_Options(b *testing.B) {
 		fr.ServeHTTP(w, req)
 	}
 }
+
+func BenchmarkRouter_CopyOnWrite_Dynamic(b *testing.B) {
+	r := NewRouter()
+	mustGET(b, r, "/user/:name/age/:age", func(w http.ResponseWriter, req *http.Request) {})
+	mustGET(b, r, "/static/path/to/resource", func(w http.ResponseWriter, req *http.Request) {})
+	r.EnableCopyOnWrite()
+
+	req, _ := http.NewRequest("GET", "/user/will/age/30", nil)
+	w := &nopRW{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(w, req)
+	}
+}
+
+// benchmarkPathValue serves a fresh shallow copy of the request per iteration, as
+// net/http does, so the cost of req.SetPathValue is not hidden by reuse.
+func benchmarkPathValue(b *testing.B, usePathValue bool) {
+	r := NewRouter()
+	r.UsePathValue = usePathValue
+	mustGET(b, r, "/user/:name/age/:age", func(w http.ResponseWriter, req *http.Request) {})
+
+	base, _ := http.NewRequest("GET", "/user/will/age/30", nil)
+	w := &nopRW{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		req := *base
+		r.ServeHTTP(w, &req)
+	}
+}
+
+func BenchmarkRouter_PathValue_Off(b *testing.B) { benchmarkPathValue(b, false) }
+func BenchmarkRouter_PathValue_On(b *testing.B)  { benchmarkPathValue(b, true) }
+
+// benchmarkFrozenRoutes serves one request per route per iteration, like the
+// go-http-routing-benchmark "All" benchmarks.
+func benchmarkFrozenRoutes(b *testing.B, routes []struct{ method, path string }, matcher FrozenMatcher) {
+	r := NewRouter()
+	h := func(w http.ResponseWriter, req *http.Request) {}
+	for _, rt := range routes {
+		if err := r.Handle(rt.method, rt.path, h); err != nil {
+			b.Fatalf("register %s %s failed: %v", rt.method, rt.path, err)
+		}
+	}
+	fr, err := r.FreezeWith(FreezeOptions{Matcher: matcher})
+	if err != nil {
+		b.Fatalf("freeze failed: %v", err)
+	}
+	reqs := make([]*http.Request, len(routes))
+	for i, rt := range routes {
+		reqs[i], _ = http.NewRequest(rt.method, samplePath(rt.path), nil)
+	}
+	w := &nopRW{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, req := range reqs {
+			fr.ServeHTTP(w, req)
+		}
+	}
+}
+
+// largeRouteSet returns 2,000 param-heavy routes spread over 40 services.
+func largeRouteSet() []struct{ method, path string } {
+	var routes []struct{ method, path string }
+	for s := 0; s < 40; s++ {
+		for res := 0; res < 25; res++ {
+			base := fmt.Sprintf("/svc%d/res%d", s, res)
+			routes = append(routes,
+				struct{ method, path string }{"GET", base + "/:id"},
+				struct{ method, path string }{"GET", base + "/:id/items/:item"},
+			)
+		}
+	}
+	return routes
+}
+
+func BenchmarkFrozen_GitHubAll_Tree(b *testing.B) { benchmarkFrozenRoutes(b, githubAPI, MatcherTree) }
+func BenchmarkFrozen_GitHubAll_Flat(b *testing.B) { benchmarkFrozenRoutes(b, githubAPI, MatcherFlat) }
+
+func BenchmarkFrozen_Large2k_Tree(b *testing.B) {
+	benchmarkFrozenRoutes(b, largeRouteSet(), MatcherTree)
+}
+
+func BenchmarkFrozen_Large2k_Flat(b *testing.B) {
+	benchmarkFrozenRoutes(b, largeRouteSet(), MatcherFlat)
+}
+
+// benchmarkFrozenMatch measures the matcher alone: paths are split up front, and each
+// iteration searches and captures the params of every route.
+func benchmarkFrozenMatch(b *testing.B, routes []struct{ method, path string }, matcher FrozenMatcher) {
+	r := NewRouter()
+	h := func(w http.ResponseWriter, req *http.Request) {}
+	for _, rt := range routes {
+		if err := r.Handle(rt.method, rt.path, h); err != nil {
+			b.Fatalf("register %s %s failed: %v", rt.method, rt.path, err)
+		}
+	}
+	fr, err := r.FreezeWith(FreezeOptions{Matcher: matcher})
+	if err != nil {
+		b.Fatalf("freeze failed: %v", err)
+	}
+	type job struct {
+		m    frozenMatcher
+		segs *pathSegments
+	}
+	jobs := make([]job, len(routes))
+	for i, rt := range routes {
+		segs, ok := fr.getParts(samplePath(rt.path))
+		if !ok {
+			b.Fatalf("split %s failed", rt.path)
+		}
+		jobs[i] = job{m: fr.table.matchers[rt.method], segs: segs}
+	}
+	params := fr.paramPool.Get().(*Params)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, j := range jobs {
+			params.Reset()
+			if j.m.match(j.segs, params) == nil {
+				b.Fatal("no match")
+			}
+		}
+	}
+}
+
+func BenchmarkFrozenMatch_GitHubAll_Tree(b *testing.B) {
+	benchmarkFrozenMatch(b, githubAPI, MatcherTree)
+}
+func BenchmarkFrozenMatch_GitHubAll_Flat(b *testing.B) {
+	benchmarkFrozenMatch(b, githubAPI, MatcherFlat)
+}
+
+func BenchmarkFrozenMatch_Large2k_Tree(b *testing.B) {
+	benchmarkFrozenMatch(b, largeRouteSet(), MatcherTree)
+}
+
+func BenchmarkFrozenMatch_Large2k_Flat(b *testing.B) {
+	benchmarkFrozenMatch(b, largeRouteSet(), MatcherFlat)
+}
+
+func BenchmarkRouter_Versioned(b *testing.B) {
+	r := NewRouter()
+	r.Versioning = VersionOptions{MediaType: "application/vnd.acme", Header: "API-Version", Default: "v1"}
+	for _, v := range []string{"v1", "v2"} {
+		if err := r.Version(v).GET("/users/:id", func(w http.ResponseWriter, req *http.Request) {}); err != nil {
+			b.Fatalf("register %s failed: %v", v, err)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/users/42", nil)
+	req.Header.Set("Accept", "application/vnd.acme.v2+json")
+	w := &nopRW{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(w, req)
+		delete(w.header, "Vary")
+	}
+}
+
+func BenchmarkRouter_HeadSuppressed(b *testing.B) {
+	r := NewRouter()
+	r.SuppressHeadBody = true
+	mustGET(b, r, "/static/path/to/resource", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	req, _ := http.NewRequest("HEAD", "/static/path/to/resource", nil)
+	w := &nopRW{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(w, req)
+		delete(w.header, "Content-Length")
+	}
+}
@@ -0,0 +1,316 @@
+package router
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// RouteKind classifies a registered route by its most dynamic segment.
+type RouteKind uint8
+
+const (
+	// RouteStatic has no parameters (e.g. /users/me).
+	RouteStatic RouteKind = iota
+	// RouteParam has at least one named parameter (e.g. /users/:id).
+	RouteParam
+	// RouteWildcard ends with a catch-all segment (e.g. /static/*filepath).
+	RouteWildcard
+	// RouteMount forwards a whole subtree to another handler (see Router.Mount).
+	RouteMount
+)
+
+func (k RouteKind) String() string {
+	switch k {
+	case RouteStatic:
+		return "static"
+	case RouteParam:
+		return "param"
+	case RouteWildcard:
+		return "wildcard"
+	case RouteMount:
+		return "mount"
+	default:
+		return "unknown"
+	}
+}
+
+// RouteInfo describes a registered route.
+// Values returned by Routes and Walk are copies and safe to modify.
+type RouteInfo struct {
+	Method  string    // HTTP method, e.g. GET
+	Host    string    // normalized host; empty for the default table
+	Pattern string    // full pattern as registered (group prefix included, original casing)
+	Name    string    // optional route name (see RouteOptions.Name)
+	Params  []string  // parameter names in path order (wildcard last)
+	Kind    RouteKind // static, param, wildcard or mount
+	Doc     *RouteDoc // optional documentation (see RouteOptions.Doc); shared, must not be modified
+	// Consumes and Produces are the normalized media types of RouteOptions.
+	Consumes []string
+	Produces []string
+
+	middlewares      []Middleware  // RouteOptions.Middlewares, re-applied by Replace
+	groupMiddlewares []Middleware  // group chain at registration, re-applied by Replace
+	casing           []caseVariant // static segment spelling, for NormalizePolicy.Case
+	pathValues       bool          // params also set on req.PathValue (ServeMux syntax)
+}
+
+func newRouteInfo(method, host, pattern string, parts []string) *RouteInfo {
+	info := &RouteInfo{
+		Method:  method,
+		Host:    host,
+		Pattern: pattern,
+		Kind:    RouteStatic,
+	}
+	for _, part := range parts {
+		if !isDynamicPart(part) {
+			continue
+		}
+		seg, err := parseSegment(part)
+		if err != nil {
+			continue
+		}
+		for _, name := range seg.params() {
+			if name != muxSubtree {
+				info.Params = append(info.Params, name)
+			}
+		}
+		if seg.kind == segmentWildcard {
+			info.Kind = RouteWildcard
+		} else if info.Kind == RouteStatic {
+			info.Kind = RouteParam
+		}
+	}
+	return info
+}
+
+func (info *RouteInfo) clone() RouteInfo {
+	c := *info
+	if info.Params != nil {
+		c.Params = append([]string(nil), info.Params...)
+	}
+	if info.Consumes != nil {
+		c.Consumes = append([]string(nil), info.Consumes...)
+	}
+	if info.Produces != nil {
+		c.Produces = append([]string(nil), info.Produces...)
+	}
+	return c
+}
+
+// Routes returns every registered route, sorted by host, pattern and method.
+func (r *Router) Routes() []RouteInfo {
+	r.mu.RLock()
+	infos := collectTableRoutes(nil, &r.table)
+	for _, table := range r.hosts {
+		infos = collectTableRoutes(infos, table)
+	}
+	r.mu.RUnlock()
+	return sortRoutes(infos)
+}
+
+// Walk calls fn for every registered route in the order returned by Routes.
+// Walking stops at the first non-nil error, which is returned.
+func (r *Router) Walk(fn func(RouteInfo) error) error {
+	return walkRoutes(r.Routes(), fn)
+}
+
+// Routes returns every route compiled into the frozen router,
+// sorted by host, pattern and method.
+func (r *FrozenRouter) Routes() []RouteInfo {
+	infos := collectFrozenRoutes(nil, &r.table)
+	for _, table := range r.hosts {
+		infos = collectFrozenRoutes(infos, table)
+	}
+	return sortRoutes(infos)
+}
+
+// Walk calls fn for every route in the order returned by Routes.
+// Walking stops at the first non-nil error, which is returned.
+func (r *FrozenRouter) Walk(fn func(RouteInfo) error) error {
+	return walkRoutes(r.Routes(), fn)
+}
+
+// RouteGetter exposes the matched route to middlewares and handlers.
+type RouteGetter interface {
+	MatchedRoute() (RouteInfo, bool)
+}
+
+// MatchedRoute returns the route that matched the request, walking Unwrap like Param.
+// It is available to middlewares registered with Use or groups, and to handlers of
+// param routes. Use it to label metrics and logs by pattern (/users/:id) instead of path.
+// The returned Params slice is shared and must not be modified.
+func MatchedRoute(w http.ResponseWriter) (RouteInfo, bool) {
+	for w != nil {
+		if rg, ok := w.(RouteGetter); ok {
+			if info, ok := rg.MatchedRoute(); ok {
+				return info, true
+			}
+		}
+		if uw, ok := w.(interface{ Unwrap() http.ResponseWriter }); ok {
+			w = uw.Unwrap()
+			continue
+		}
+		break
+	}
+	return RouteInfo{}, false
+}
+
+var routeRWPool = sync.Pool{
+	New: func() interface{} { return &paramRW{} },
+}
+
+// withRoute exposes info to the middleware chain of a route. Param routes already
+// carry their route on the router's paramRW, so only static routes are wrapped.
+func withRoute(info *RouteInfo, next HandleFunc) HandleFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if prw, ok := w.(*paramRW); ok && prw.route == info {
+			next(w, req)
+			return
+		}
+		prw := routeRWPool.Get().(*paramRW)
+		prw.ResponseWriter = w
+		prw.route = info
+		next(prw, req)
+		resetParamRW(prw)
+		routeRWPool.Put(prw)
+	}
+}
+
+func collectTableRoutes(dst []*RouteInfo, table *routeTable) []*RouteInfo {
+	if table == nil {
+		return dst
+	}
+	for _, root := range table.roots {
+		dst = collectNodeRoutes(dst, root)
+	}
+	for i := range table.mounts {
+		dst = append(dst, table.mounts[i].route)
+	}
+	return dst
+}
+
+func collectNodeRoutes(dst []*RouteInfo, n *node) []*RouteInfo {
+	if n == nil {
+		return dst
+	}
+	if n.route != nil {
+		dst = append(dst, n.route)
+	}
+	n.staticChildren.rangeFn(func(_ string, child *node) bool {
+		dst = collectNodeRoutes(dst, child)
+		return true
+	})
+	for _, child := range n.mixed {
+		dst = collectNodeRoutes(dst, child)
+	}
+	for _, child := range n.constrained {
+		dst = collectNodeRoutes(dst, child)
+	}
+	dst = collectNodeRoutes(dst, n.paramChild)
+	return collectNodeRoutes(dst, n.wildChild)
+}
+
+func collectFrozenRoutes(dst []*RouteInfo, table *frozenTable) []*RouteInfo {
+	if table == nil {
+		return dst
+	}
+	for _, root := range table.roots {
+		dst = collectFrozenNodeRoutes(dst, root)
+	}
+	for i := range table.mounts {
+		dst = append(dst, table.mounts[i].route)
+	}
+	return dst
+}
+
+func collectFrozenNodeRoutes(dst []*RouteInfo, n *frozenNode) []*RouteInfo {
+	if n == nil {
+		return dst
+	}
+	if n.route != nil {
+		dst = append(dst, n.route)
+	}
+	if n.staticChildren != nil {
+		if n.staticChildren.m != nil {
+			for _, child := range n.staticChildren.m {
+				dst = collectFrozenNodeRoutes(dst, child)
+			}
+		} else {
+			for i := range n.staticChildren.small {
+				dst = collectFrozenNodeRoutes(dst, n.staticChildren.small[i].node)
+			}
+		}
+	}
+	for _, child := range n.mixed {
+		dst = collectFrozenNodeRoutes(dst, child)
+	}
+	for _, child := range n.constrained {
+		dst = collectFrozenNodeRoutes(dst, child)
+	}
+	dst = collectFrozenNodeRoutes(dst, n.paramChild)
+	return collectFrozenNodeRoutes(dst, n.wildChild)
+}
+
+func sortRoutes(infos []*RouteInfo) []RouteInfo {
+	// Variants of one route (optional segments) share a *RouteInfo: list it once.
+	seen := make(map[*RouteInfo]struct{}, len(infos))
+	uniq := infos[:0]
+	for _, info := range infos {
+		if _, ok := seen[info]; ok {
+			continue
+		}
+		seen[info] = struct{}{}
+		uniq = append(uniq, info)
+	}
+	infos = uniq
+	sort.Slice(infos, func(i, j int) bool {
+		a, b := infos[i], infos[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Pattern != b.Pattern {
+			return a.Pattern < b.Pattern
+		}
+		return methodLess(a.Method, b.Method)
+	})
+	out := make([]RouteInfo, len(infos))
+	for i, info := range infos {
+		out[i] = info.clone()
+	}
+	return out
+}
+
+// methodLess orders standard methods as in the Allow header, custom methods last.
+func methodLess(a, b string) bool {
+	ra, aStd := methodRank(a)
+	rb, bStd := methodRank(b)
+	if aStd && bStd {
+		return ra < rb
+	}
+	if aStd != bStd {
+		return aStd
+	}
+	return a < b
+}
+
+func methodRank(method string) (int, bool) {
+	for i, m := range methodOrder {
+		if m.method == method {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func walkRoutes(routes []RouteInfo, fn func(RouteInfo) error) error {
+	if fn == nil {
+		return nil
+	}
+	for _, info := range routes {
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
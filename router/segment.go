@@ -0,0 +1,384 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+)
+
+// segmentKind classifies a '/'-delimited pattern segment.
+type segmentKind uint8
+
+const (
+	segmentStatic   segmentKind = iota // "users"
+	segmentParam                       // ":id" or ":id<int>"
+	segmentMixed                       // ":{file}.zip", "v:{major}", ":{name}.:{ext}"
+	segmentWildcard                    // "*filepath"
+)
+
+// segmentToken is a literal run or a param inside a pattern segment.
+type segmentToken struct {
+	literal    string           // literal text (literals only)
+	name       string           // param name (params only)
+	expr       string           // constraint expression (params only, optional)
+	constraint *paramConstraint // compiled expr (set by compileTemplate)
+}
+
+// segment is a parsed pattern segment.
+// A segment is static unless it starts with ':' (a param filling the whole segment) or
+// '*' (a wildcard), or contains ":{" (a mixed segment). A whole-segment param is named
+// by the rest of the segment, as it always was. Inside a mixed segment every param is
+// delimited and named with [A-Za-z0-9_], so ":{file}.zip" is the param "file" followed
+// by the literal ".zip", while "things:search" stays static.
+type segment struct {
+	kind   segmentKind
+	tokens []segmentToken
+}
+
+func isParamNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// parseSegment parses a single pattern segment (no '/').
+func parseSegment(part string) (segment, error) {
+	if part == "" {
+		return segment{kind: segmentStatic}, nil
+	}
+	if part[0] == '*' {
+		name := part[1:]
+		if name == "" {
+			return segment{}, fmt.Errorf("wildcard must have a name (e.g., *filepath)")
+		}
+		if strings.ContainsAny(name, "<>") {
+			return segment{}, fmt.Errorf("constraints are not supported on wildcard: %s", part)
+		}
+		return segment{kind: segmentWildcard, tokens: []segmentToken{{name: name}}}, nil
+	}
+	if strings.Contains(part, ":{") {
+		return parseMixedSegment(part)
+	}
+	if part[0] != ':' {
+		return segment{kind: segmentStatic, tokens: []segmentToken{{literal: part}}}, nil
+	}
+
+	// A whole-segment param keeps the original naming rule: the name runs to the end of
+	// the segment or to its constraint, so ":user-id" is the param "user-id".
+	tok := segmentToken{name: part[1:]}
+	if lt := strings.IndexByte(tok.name, '<'); lt >= 0 {
+		tok.name = part[1 : lt+1]
+		end, ok := constraintEnd(part, lt+1)
+		if !ok || end == lt+2 {
+			return segment{}, fmt.Errorf("invalid constraint in segment: %s", part)
+		}
+		if end != len(part)-1 {
+			return segment{}, fmt.Errorf("constraint must end the segment: %s", part)
+		}
+		tok.expr = part[lt+2 : end]
+	}
+	if tok.name == "" {
+		return segment{}, fmt.Errorf("parameter must have a name (e.g., :id)")
+	}
+	return segment{kind: segmentParam, tokens: []segmentToken{tok}}, nil
+}
+
+// parseMixedSegment parses a segment mixing literals and delimited params
+// (":{file}.zip", "v:{major}", ":{name}.:{ext}").
+func parseMixedSegment(part string) (segment, error) {
+	var tokens []segmentToken
+	litStart := 0
+	i := 0
+	for i < len(part) {
+		if part[i] != ':' || i+1 == len(part) {
+			i++
+			continue
+		}
+		if part[i+1] != '{' {
+			if isParamNameChar(part[i+1]) {
+				return segment{}, fmt.Errorf("params inside a segment must be written as :{name}: %s", part)
+			}
+			i++ // literal ':'
+			continue
+		}
+		if litStart < i {
+			tokens = append(tokens, segmentToken{literal: part[litStart:i]})
+		}
+		tok, end, err := parseParamToken(part, i+2)
+		if err != nil {
+			return segment{}, err
+		}
+		if end >= len(part) || part[end] != '}' {
+			return segment{}, fmt.Errorf("invalid param in segment (expected :{name}): %s", part)
+		}
+		i = end + 1
+		if n := len(tokens); n > 0 && tokens[n-1].name != "" {
+			return segment{}, fmt.Errorf("adjacent parameters must be separated by a literal: %s", part)
+		}
+		tokens = append(tokens, tok)
+		litStart = i
+	}
+	if litStart < len(part) {
+		tokens = append(tokens, segmentToken{literal: part[litStart:]})
+	}
+	if len(tokens) == 1 {
+		return segment{kind: segmentParam, tokens: tokens}, nil
+	}
+	return segment{kind: segmentMixed, tokens: tokens}, nil
+}
+
+// parseParamToken parses a ":{...}" param name starting at i, with an optional "<expr>"
+// constraint, and returns the index after it.
+func parseParamToken(part string, i int) (segmentToken, int, error) {
+	nameStart := i
+	for i < len(part) && isParamNameChar(part[i]) {
+		i++
+	}
+	tok := segmentToken{name: part[nameStart:i]}
+	if tok.name == "" {
+		return tok, i, fmt.Errorf("parameter must have a name (e.g., :id)")
+	}
+	if i < len(part) && part[i] == '<' {
+		end, ok := constraintEnd(part, i)
+		if !ok || end == i+1 {
+			return tok, i, fmt.Errorf("invalid constraint in segment: %s", part)
+		}
+		tok.expr = part[i+1 : end]
+		i = end + 1
+	}
+	return tok, i, nil
+}
+
+// constraintEnd returns the index of the '>' closing the '<' at open (nesting aware).
+func constraintEnd(part string, open int) (int, bool) {
+	depth := 0
+	for i := open; i < len(part); i++ {
+		switch part[i] {
+		case '<':
+			depth++
+		case '>':
+			depth--
+			if depth == 0 {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// checkConstraintSlashes rejects a '/' inside a "<...>" constraint: patterns are split
+// into segments before constraints are parsed, and a param never spans segments anyway.
+func checkConstraintSlashes(pattern string) error {
+	depth := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '<':
+			depth++
+		case '>':
+			if depth > 0 {
+				depth--
+			}
+		case '/':
+			if depth > 0 {
+				return fmt.Errorf("constraints cannot contain '/' (a param matches a single segment): %s", pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// params returns the param names of the segment in order.
+func (s segment) params() []string {
+	var names []string
+	for _, tok := range s.tokens {
+		if tok.name != "" {
+			names = append(names, tok.name)
+		}
+	}
+	return names
+}
+
+// paramName returns the name of a whole-segment param or wildcard.
+func (s segment) paramName() string {
+	if len(s.tokens) == 0 {
+		return ""
+	}
+	return s.tokens[0].name
+}
+
+// isDynamicPart reports whether a pattern segment contains a param or wildcard.
+func isDynamicPart(part string) bool {
+	return len(part) > 0 && (part[0] == '*' || part[0] == ':' || strings.Contains(part, ":{"))
+}
+
+// lowerSegmentLiterals lowercases the literal parts of a pattern segment for IgnoreCase,
+// leaving param names and constraints untouched.
+func lowerSegmentLiterals(part string) string {
+	if !isDynamicPart(part) {
+		return lowerASCII(part)
+	}
+	seg, err := parseSegment(part)
+	if err != nil || seg.kind != segmentMixed {
+		return part
+	}
+	var b strings.Builder
+	b.Grow(len(part))
+	for _, tok := range seg.tokens {
+		if tok.name == "" {
+			b.WriteString(lowerASCII(tok.literal))
+			continue
+		}
+		b.WriteString(":{")
+		b.WriteString(tok.name)
+		if tok.expr != "" {
+			b.WriteByte('<')
+			b.WriteString(tok.expr)
+			b.WriteByte('>')
+		}
+		b.WriteByte('}')
+	}
+	return b.String()
+}
+
+// segmentTemplate matches a mixed segment (literals with embedded params).
+type segmentTemplate struct {
+	key        string // structure without param names (conflict detection)
+	tokens     []segmentToken
+	literalLen int // total literal bytes; higher is tried first
+}
+
+func compileTemplate(seg segment) (*segmentTemplate, error) {
+	t := &segmentTemplate{tokens: make([]segmentToken, len(seg.tokens))}
+	var key strings.Builder
+	for i, tok := range seg.tokens {
+		if tok.name == "" {
+			t.literalLen += len(tok.literal)
+			key.WriteString(tok.literal)
+		} else {
+			key.WriteByte(0)
+			key.WriteString(tok.expr)
+			key.WriteByte(0)
+			if tok.expr != "" {
+				c, err := compileConstraint(tok.expr)
+				if err != nil {
+					return nil, err
+				}
+				tok.constraint = c
+			}
+		}
+		t.tokens[i] = tok
+	}
+	t.key = key.String()
+	return t, nil
+}
+
+// match matches a path segment against the template and captures params.
+// m is the (possibly lowercased) segment used for literals, v the original segment
+// used for values; both have the same length. A param ends at the first occurrence of
+// the literal that follows it, except before the final literal, which must be a suffix.
+// On failure the caller must roll back params.
+func (t *segmentTemplate) match(m, v string, params *Params) bool {
+	pos := 0
+	toks := t.tokens
+	for i := range toks {
+		tok := &toks[i]
+		if tok.name == "" {
+			if !strings.HasPrefix(m[pos:], tok.literal) {
+				return false
+			}
+			pos += len(tok.literal)
+			continue
+		}
+		end := len(m)
+		if i+1 < len(toks) {
+			lit := toks[i+1].literal
+			if i+2 == len(toks) {
+				end = len(m) - len(lit)
+			} else {
+				if pos+1 > len(m) {
+					return false
+				}
+				idx := strings.Index(m[pos+1:], lit)
+				if idx < 0 {
+					return false
+				}
+				end = pos + 1 + idx
+			}
+		}
+		if end <= pos {
+			return false
+		}
+		value := v[pos:end]
+		if tok.constraint != nil && !tok.constraint.match(value) {
+			return false
+		}
+		if params != nil {
+			params.Add(tok.name, value)
+		}
+		pos = end
+	}
+	return pos == len(m)
+}
+
+// expandOptional expands a trailing optional part into concrete patterns, shortest first:
+//
+//	/articles/:slug?          -> /articles, /articles/:slug
+//	/reports(/:year/:month)?  -> /reports, /reports/:year/:month
+//
+// Only the end of a pattern may be optional; patterns without '?' are returned as is.
+// A '?' inside a constraint (":l<en(-us)?>") is part of the constraint.
+func expandOptional(pattern string) ([]string, error) {
+	p := pattern
+	trailing := ""
+	if len(p) > 1 && p[len(p)-1] == '/' {
+		p = p[:len(p)-1]
+		trailing = "/"
+	}
+	if !strings.HasSuffix(p, "?") {
+		if indexOutsideConstraints(pattern, "?") >= 0 {
+			return nil, fmt.Errorf("only the end of a pattern can be optional: %s", pattern)
+		}
+		return []string{pattern}, nil
+	}
+
+	var base, full string
+	if strings.HasSuffix(p, ")?") {
+		open := strings.LastIndex(p, "(/")
+		if open < 0 {
+			return nil, fmt.Errorf("optional group must look like (/...)?: %s", pattern)
+		}
+		inner := p[open+1 : len(p)-2]
+		if indexOutsideConstraints(inner, "()?") >= 0 {
+			return nil, fmt.Errorf("nested optional groups are not supported: %s", pattern)
+		}
+		base, full = p[:open], p[:open]+inner
+	} else {
+		slash := strings.LastIndexByte(p, '/')
+		if !isDynamicPart(p[slash+1 : len(p)-1]) {
+			return nil, fmt.Errorf("only params can be optional (e.g., :slug?): %s", pattern)
+		}
+		base, full = p[:slash], p[:len(p)-1]
+	}
+	if indexOutsideConstraints(full, "?") >= 0 {
+		return nil, fmt.Errorf("only the end of a pattern can be optional: %s", pattern)
+	}
+	if base == "" {
+		base = "/"
+	} else {
+		base += trailing
+	}
+	return []string{base, full + trailing}, nil
+}
+
+// indexOutsideConstraints is strings.IndexAny skipping "<...>" constraint spans.
+func indexOutsideConstraints(s, chars string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '<':
+			depth++
+		case c == '>' && depth > 0:
+			depth--
+		case depth == 0 && strings.IndexByte(chars, c) >= 0:
+			return i
+		}
+	}
+	return -1
+}
@@ -1,6 +1,9 @@
 package router
 
-import "net/http"
+import (
+	"net/http"
+	"strings"
+)
 
 const allowHeaderKey = "Allow"
 
@@ -11,61 +14,103 @@ type routeContext struct {
 	matchPath  string // normalized path for matching (may be lowercased)
 	paramPath  string // path for parameter extraction (raw or decoded)
 	useRaw     bool
+	normalized Normalization // normalizations rewritten into the request
+	// canonicalCase defers the Case normalization to the matched route, which tells
+	// static segments from param values.
+	canonicalCase bool
+	// redirect is a Clean redirect to paramPath waiting for canonicalCase, so that the
+	// client is redirected once.
+	redirect   bool
 	redirectFn func(http.ResponseWriter, *http.Request, string)
 }
 
-// prepareRouteContext preprocesses the request and returns a routeContext.
-// Returns nil if the request should not be processed further (e.g., already responded).
-func prepareRouteContext(w http.ResponseWriter, req *http.Request, useRawPath, ignoreCase bool) (routeContext, bool) {
+// prepareRouteContext preprocesses the request and returns a routeContext, and the
+// request to serve (a rewritten copy when policy rewrites the path).
+// Returns false if the request should not be processed further (e.g., already responded).
+func prepareRouteContext(w http.ResponseWriter, req *http.Request, useRawPath, ignoreCase bool, policy NormalizePolicy) (routeContext, *http.Request, bool) {
 	useRaw := useRawPath && req.URL.RawPath != "" && req.URL.RawPath == req.URL.EscapedPath()
 
 	if len(req.URL.Path) > MaxPathLength {
 		w.WriteHeader(http.StatusRequestURITooLong)
-		return routeContext{}, false
+		return routeContext{}, req, false
 	}
 	if useRaw && len(req.URL.RawPath) > MaxPathLength {
 		w.WriteHeader(http.StatusRequestURITooLong)
-		return routeContext{}, false
+		return routeContext{}, req, false
 	}
 
-	cleaned := req.URL.Path
-	if !useRaw {
-		cleaned = cleanPath(req.URL.Path)
+	var normalized Normalization
+	redirect := false
+	paramPath := req.URL.Path
+	redirectFn := redirectToPath
+	if useRaw {
+		paramPath = req.URL.RawPath
+		redirectFn = redirectToRawPath
+	} else if mode := policy.clean(); mode == NormalizePassThrough {
+		if hasDotSegment(paramPath) {
+			respondBadPath(w)
+			return routeContext{}, req, false
+		}
+	} else {
+		cleaned := cleanPath(paramPath)
 		if len(cleaned) > MaxPathLength {
 			w.WriteHeader(http.StatusRequestURITooLong)
-			return routeContext{}, false
+			return routeContext{}, req, false
 		}
-		if cleaned != req.URL.Path {
-			redirectToPath(w, req, cleaned)
-			return routeContext{}, false
+		if cleaned != paramPath {
+			switch mode {
+			case NormalizeReject:
+				respondBadPath(w)
+				return routeContext{}, req, false
+			case NormalizeRewrite:
+				normalized |= NormalizedClean
+			default:
+				redirect = true
+			}
+			paramPath = cleaned
 		}
 	}
 
-	matchPath := cleaned
-	paramPath := cleaned
-	redirectFn := redirectToPath
-	if useRaw {
-		matchPath = req.URL.RawPath
-		paramPath = req.URL.RawPath
-		redirectFn = redirectToRawPath
-	}
+	matchPath := paramPath
+	canonicalCase := false
 	if ignoreCase {
-		matchPath = lowerASCII(matchPath)
+		matchPath = lowerASCII(paramPath)
+		// Case only applies to static segments, so it waits for the matched route.
+		if policy.foldCase() != NormalizePassThrough && (policy.CanonicalCase || matchPath != paramPath) {
+			canonicalCase = true
+		}
+	}
+
+	// Redirect once to the fully normalized path.
+	if redirect && !canonicalCase {
+		redirectFn(w, req, paramPath)
+		return routeContext{}, req, false
+	}
+	if normalized != 0 {
+		req = rewriteRequest(req, paramPath, useRaw)
 	}
 
 	return routeContext{
-		method:     req.Method,
-		matchPath:  matchPath,
-		paramPath:  paramPath,
-		useRaw:     useRaw,
-		redirectFn: redirectFn,
-	}, true
+		method:        req.Method,
+		matchPath:     matchPath,
+		paramPath:     paramPath,
+		useRaw:        useRaw,
+		normalized:    normalized,
+		canonicalCase: canonicalCase,
+		redirect:      redirect,
+		redirectFn:    redirectFn,
+	}, req, true
 }
 
-// respondMethodNotAllowed writes the 405 response with Allow header.
-func respondMethodNotAllowed(w http.ResponseWriter, req *http.Request, allow string, handler HandleFunc) bool {
+// respondMethodNotAllowed writes the 405 response with Allow header, or answers OPTIONS
+// (through optionsHandler when set) unless autoOptions is off.
+func respondMethodNotAllowed(w http.ResponseWriter, req *http.Request, allow string, handler, optionsHandler HandleFunc, autoOptions bool) bool {
 	setAllowHeader(w, allow)
-	if req.Method == http.MethodOptions {
+	if req.Method == http.MethodOptions && autoOptions {
+		if optionsHandler != nil {
+			optionsHandler(w, req)
+			return true
+		}
 		w.WriteHeader(http.StatusOK)
 		return true
 	}
@@ -87,6 +132,22 @@ func setAllowHeader(w http.ResponseWriter, allow string) {
 	h[allowHeaderKey] = []string{allow}
 }
 
+// hasDotSegment reports whether p has a "." or ".." segment.
+func hasDotSegment(p string) bool {
+	for len(p) > 0 {
+		seg := p
+		if i := strings.IndexByte(p, '/'); i >= 0 {
+			seg, p = p[:i], p[i+1:]
+		} else {
+			p = ""
+		}
+		if seg == "." || seg == ".." {
+			return true
+		}
+	}
+	return false
+}
+
 // alternatePath returns the path with the trailing slash toggled.
 func alternatePath(p string) (string, bool) {
 	if p == "" || p == "/" {
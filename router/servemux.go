@@ -0,0 +1,150 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// muxSubtree names the wildcard that implements ServeMux subtree patterns ("/static/").
+// It is not a valid param name, so it cannot collide with user params, and it is not
+// exposed through req.PathValue.
+const muxSubtree = "..."
+
+// HandleFunc registers handler with a net/http ServeMux pattern,
+// "[METHOD ][HOST]/[PATH]", so ServeMux registrations can move over verbatim:
+//
+//	r.HandleFunc("GET /users/{id}", show)        // -> GET /users/:id
+//	r.HandleFunc("/files/{path...}", files)      // -> every method, /files/*path
+//	r.HandleFunc("api.example.com/{$}", apiRoot) // -> host api.example.com, exactly /
+//
+// Without a method the route matches every method (MethodAny); GET also serves HEAD.
+// A trailing '/' matches the whole subtree and {$} matches only the path as written.
+// Params are readable with both Param and req.PathValue.
+func (r *Router) HandleFunc(pattern string, handler HandleFunc) error {
+	method, host, path, err := splitMuxPattern(pattern)
+	if err != nil {
+		return err
+	}
+	if handler == nil {
+		return fmt.Errorf("nil handler for route: %s", pattern)
+	}
+	return r.handle(host, method, path, handler, nil, RouteOptions{pathValues: true})
+}
+
+// HandleFunc registers a ServeMux pattern under the group's prefix. See Router.HandleFunc.
+// The pattern must not name a host; use Router.Host for that.
+func (g *Group) HandleFunc(pattern string, handler HandleFunc) error {
+	method, host, path, err := splitMuxPattern(pattern)
+	if err != nil {
+		return err
+	}
+	if host != "" {
+		return fmt.Errorf("host in pattern is not supported in groups: %s", pattern)
+	}
+	if handler == nil {
+		return fmt.Errorf("nil handler for route: %s", pattern)
+	}
+	return g.router.handle(g.host, method, joinPaths(g.prefix, path), handler, g.middlewares, RouteOptions{pathValues: true})
+}
+
+// splitMuxPattern splits "[METHOD ][HOST]/[PATH]"; a missing method becomes MethodAny.
+func splitMuxPattern(pattern string) (method, host, path string, err error) {
+	method = MethodAny
+	rest := strings.TrimLeft(pattern, " \t")
+	if i := strings.IndexAny(rest, " \t"); i >= 0 {
+		method, rest = rest[:i], strings.TrimLeft(rest[i:], " \t")
+	}
+	slash := strings.IndexByte(rest, '/')
+	if slash < 0 {
+		return "", "", "", fmt.Errorf("pattern must contain a path starting with '/': %s", pattern)
+	}
+	path, err = translateMuxPath(rest[slash:], true)
+	return method, rest[:slash], path, err
+}
+
+// translateMuxPath converts ServeMux path syntax to trie syntax:
+// {name} -> :name, {name...} -> *name, {$} -> exact trailing slash,
+// and a trailing '/' -> subtree wildcard when subtree is set (HandleFunc), or an
+// exact trailing slash otherwise (brace paths in Handle).
+func translateMuxPath(p string, subtree bool) (string, error) {
+	if p == "" || p[0] != '/' {
+		return "", fmt.Errorf("pattern must start with '/': %s", p)
+	}
+	parts := strings.Split(p[1:], "/")
+	var b strings.Builder
+	b.Grow(len(p) + len(muxSubtree))
+	for i, part := range parts {
+		last := i == len(parts)-1
+		b.WriteByte('/')
+		switch {
+		case part == "" && last:
+			if subtree {
+				b.WriteByte('*')
+				b.WriteString(muxSubtree)
+			}
+		case part == "{$}":
+			if !last {
+				return "", fmt.Errorf("{$} must be at the end of the pattern: %s", p)
+			}
+		case len(part) > 1 && part[0] == '{' && part[len(part)-1] == '}':
+			name := part[1 : len(part)-1]
+			prefix := byte(':')
+			if strings.HasSuffix(name, "...") {
+				if !last {
+					return "", fmt.Errorf("{%s} must be at the end of the pattern: %s", name, p)
+				}
+				name = strings.TrimSuffix(name, "...")
+				prefix = '*'
+			}
+			if name == "" {
+				return "", fmt.Errorf("wildcard must have a name in pattern: %s", p)
+			}
+			for j := 0; j < len(name); j++ {
+				if !isParamNameChar(name[j]) {
+					return "", fmt.Errorf("invalid wildcard name '%s' in pattern: %s", name, p)
+				}
+			}
+			b.WriteByte(prefix)
+			b.WriteString(name)
+		case strings.ContainsAny(part, "{}"):
+			return "", fmt.Errorf("wildcard must be a whole segment in pattern: %s", p)
+		case isDynamicPart(part) || strings.IndexByte(part, '?') >= 0:
+			return "", fmt.Errorf("':', '*' and '?' are not supported in ServeMux patterns: %s", p)
+		default:
+			b.WriteString(part)
+		}
+	}
+	return b.String(), nil
+}
+
+// braceToPattern translates patterns written with ServeMux braces (/users/{id}).
+// Braces inside constraints (:code<[A-Z]{3}>) never start a segment, so they are left alone.
+// A trailing '/' keeps its Handle meaning (exact trailing slash); only HandleFunc turns
+// it into a subtree.
+func braceToPattern(pattern string) (string, bool, error) {
+	if !strings.Contains(pattern, "/{") {
+		return pattern, false, nil
+	}
+	translated, err := translateMuxPath(pattern, false)
+	return translated, true, err
+}
+
+// withPathValues copies the route params onto the request for req.PathValue.
+// Params are found on the router's writers through Unwrap, so it can sit under middlewares;
+// writers whose params UsePathValue already copied are skipped.
+func withPathValues(next HandleFunc) HandleFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		for rw := w; rw != nil; {
+			if prw, ok := rw.(*paramRW); ok && prw.params != nil && !prw.pathValues {
+				setPathValues(req, prw.params)
+			}
+			uw, ok := rw.(interface{ Unwrap() http.ResponseWriter })
+			if !ok {
+				break
+			}
+			rw = uw.Unwrap()
+		}
+		next(w, req)
+	}
+}
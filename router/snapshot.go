@@ -0,0 +1,52 @@
+package router
+
+import "sync/atomic"
+
+// snapshotState holds the copy-on-write state of a Router.
+// The published snapshot is read without locks; everything else is guarded by Router.mu.
+type snapshotState struct {
+	current    atomic.Pointer[FrozenRouter]
+	enabled    bool
+	batchDepth int
+}
+
+// EnableCopyOnWrite switches the router to copy-on-write serving.
+//
+// Every registration then rebuilds an immutable FrozenRouter (see Freeze) and publishes
+// it atomically; ServeHTTP reads the published snapshot without taking any lock, so
+// runtime registration never stalls requests. Writes become O(routes); use Batch to
+// publish many registrations at once.
+//
+// Config fields (NotFound, StrictSlash, PanicHandler, ...) are captured on publish, so
+// set them before enabling.
+func (r *Router) EnableCopyOnWrite() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.snapshot.enabled = true
+	r.publishLocked()
+}
+
+// Batch runs fn and publishes its registrations as a single snapshot in copy-on-write
+// mode. Requests keep seeing the previous snapshot until fn returns. Routes registered
+// before fn fails are kept. Without copy-on-write, Batch just calls fn.
+func (r *Router) Batch(fn func() error) error {
+	r.mu.Lock()
+	r.snapshot.batchDepth++
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.snapshot.batchDepth--
+		r.publishLocked()
+		r.mu.Unlock()
+	}()
+	return fn()
+}
+
+// publishLocked rebuilds and publishes the snapshot. Caller must hold r.mu.
+func (r *Router) publishLocked() {
+	if !r.snapshot.enabled || r.snapshot.batchDepth > 0 {
+		return
+	}
+	r.snapshot.current.Store(r.freezeLocked(MatcherTree))
+}
@@ -14,17 +14,32 @@ type node struct {
 	// Structured children (O(1) hot path + two pointers).
 	// [Design]: We use distinct fields for different node types to minimize pointer chasing and type assertions.
 	// - staticChildren: For exact string matches (e.g. "users").
+	// - mixed:          For literals with embedded params (e.g. ":{file}.zip", "v:{major}").
+	// - constrained:    For validated params (e.g. ":id<int>"). Tried in registration order.
 	// - paramChild:     For wildcard matches (e.g. ":id"). Only one per level allowed.
 	// - wildChild:      For catch-all matches (e.g. "*any"). Must be at the end.
 	staticChildren *staticChildren // static children: part -> *node
+	mixed          []*node         // mixed-segment children (most literal bytes first)
+	constrained    []*node         // constrained param children (one per distinct constraint)
 	paramChild     *node           // param child (at most one per level)
 	wildChild      *node           // wildcard child (at most one per level; '*' must be last)
 
+	// Param/wildcard nodes only: param name (without ':'/'*' or constraint) and validator.
+	name       string
+	constraint *paramConstraint
+	// Mixed nodes only: compiled segment template.
+	template *segmentTemplate
+
 	// Leaf nodes store handler directly (avoid Router.handlers map + key build).
 	handler HandleFunc
 
 	// [Optimization]: leaf-only flag for param routes (skip params on static routes).
 	hasParams bool
+
+	// route describes the registered route (leaf only). Used for introspection.
+	route *RouteInfo
+	// versions dispatches a versioned route (leaf only; see Router.Version).
+	versions *versionSet
 }
 
 const staticChildThreshold = 4
@@ -126,12 +141,25 @@ func (s *staticChildren) set(part string, child *node) {
 	s.m = m
 }
 
+func (s *staticChildren) del(part string) {
+	if s.m != nil {
+		delete(s.m, part)
+		return
+	}
+	for i := range s.small {
+		if s.small[i].part == part {
+			s.small = append(s.small[:i], s.small[i+1:]...)
+			return
+		}
+	}
+}
+
 func (n *node) String() string {
 	return "node{pattern=" + n.pattern + ", part=" + n.part + "}"
 }
 
 // insert recursively inserts a route (fail fast).
-func (n *node) insert(pattern string, parts []string, height int, handler HandleFunc, routeHasParams bool) error {
+func (n *node) insert(pattern string, parts []string, height int, handler HandleFunc, route *RouteInfo, routeHasParams bool) error {
 	// [Safety]: DoS protection (depth explosion).
 	if height > MaxDepth {
 		return fmt.Errorf("route too deep, possible DoS attack: %s", pattern)
@@ -148,30 +176,22 @@ func (n *node) insert(pattern string, parts []string, height int, handler Handle
 		n.pattern = pattern
 		n.handler = handler // attach handler
 		n.hasParams = routeHasParams
+		n.route = route
 		return nil
 	}
 
 	part := parts[height]
-	if len(part) > 0 && (part[0] == ':' || part[0] == '*') {
+	seg, err := parseSegment(part)
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, pattern)
+	}
+	if seg.kind != segmentStatic {
 		routeHasParams = true
 	}
 
-	// registration-time validation
-	if len(part) > 0 {
-		if part[0] == '*' {
-			// '*' must be the last segment
-			if len(parts) > height+1 {
-				return fmt.Errorf("wildcard * must be at the end of path: %s", pattern)
-			}
-			if len(part) == 1 {
-				return fmt.Errorf("wildcard must have a name (e.g., *filepath): %s", pattern)
-			}
-		}
-		if part[0] == ':' {
-			if len(part) == 1 {
-				return fmt.Errorf("parameter must have a name (e.g., :id): %s", pattern)
-			}
-		}
+	// registration-time validation: '*' must be the last segment
+	if seg.kind == segmentWildcard && len(parts) > height+1 {
+		return fmt.Errorf("wildcard * must be at the end of path: %s", pattern)
 	}
 
 	cleaned, err := sanitizePart(part)
@@ -181,33 +201,60 @@ func (n *node) insert(pattern string, parts []string, height int, handler Handle
 	part = cleaned
 
 	// select or create child
-	child := n.matchChildForInsert(part)
+	child, err := n.matchChildForInsert(part, seg)
+	if err != nil {
+		return fmt.Errorf("%v in path '%s'", err, pattern)
+	}
 	if child != nil {
 		// [Conflict Detection]: detect same-level param/wildcard name conflicts.
-		// Rule: /users/:id and /users/:name is a conflict.
-		if len(part) > 0 {
-			if part[0] == ':' && child.part != part {
+		// Rule: /users/:id and /users/:name is a conflict (same for equal constraints
+		// and for mixed segments with the same literals, e.g. :{a}.zip vs :{b}.zip).
+		switch seg.kind {
+		case segmentParam, segmentMixed:
+			if child.part != part {
 				return fmt.Errorf("conflict: parameter '%s' conflicts with existing '%s' in path '%s' at index %d", part, child.part, pattern, height)
 			}
-			if part[0] == '*' && child.part != part {
+		case segmentWildcard:
+			if child.part != part {
 				return fmt.Errorf("conflict: wildcard '%s' conflicts with existing '%s' in path '%s' at index %d", part, child.part, pattern, height)
 			}
 		}
 	} else {
 		child = &node{part: part}
-		switch part[0] {
-		case ':':
+		switch seg.kind {
+		case segmentParam:
+			tok := seg.tokens[0]
+			child.name = tok.name
+			if tok.expr != "" {
+				// Constrained params may coexist with each other and with a wildcard:
+				// a value rejected by the constraint falls through to the next candidate.
+				c, err := compileConstraint(tok.expr)
+				if err != nil {
+					return fmt.Errorf("%v in path '%s'", err, pattern)
+				}
+				child.constraint = c
+				n.constrained = append(n.constrained, child)
+				break
+			}
 			// [Conflict Detection]: only one of param or wildcard per level
 			if n.wildChild != nil {
 				return fmt.Errorf("conflict: parameter '%s' conflicts with existing wildcard '%s' in path '%s' at index %d", part, n.wildChild.part, pattern, height)
 			}
 			// one param child per level
 			n.paramChild = child
-		case '*':
+		case segmentMixed:
+			tmpl, err := compileTemplate(seg)
+			if err != nil {
+				return fmt.Errorf("%v in path '%s'", err, pattern)
+			}
+			child.template = tmpl
+			n.addMixed(child)
+		case segmentWildcard:
 			// [Conflict Detection]: only one of param or wildcard per level
 			if n.paramChild != nil {
 				return fmt.Errorf("conflict: wildcard '%s' conflicts with existing parameter '%s' in path '%s' at index %d", part, n.paramChild.part, pattern, height)
 			}
+			child.name = seg.paramName()
 			// one wildcard child per level
 			n.wildChild = child
 		default:
@@ -218,17 +265,131 @@ func (n *node) insert(pattern string, parts []string, height int, handler Handle
 		}
 	}
 
-	return child.insert(pattern, parts, height+1, handler, routeHasParams)
+	return child.insert(pattern, parts, height+1, handler, route, routeHasParams)
+}
+
+// remove detaches the leaf registered for parts and prunes nodes left empty.
+// parts must be the (normalized) parts used at insert time.
+func (n *node) remove(parts []string, height int) bool {
+	if height == len(parts) {
+		if n.pattern == "" {
+			return false
+		}
+		n.pattern = ""
+		n.handler = nil
+		n.hasParams = false
+		n.route = nil
+		return true
+	}
+	part := parts[height]
+	seg, err := parseSegment(part)
+	if err != nil {
+		return false
+	}
+	child, err := n.matchChildForInsert(part, seg)
+	if err != nil || child == nil || child.part != part {
+		return false
+	}
+	if !child.remove(parts, height+1) {
+		return false
+	}
+	if child.isEmpty() {
+		n.removeChild(child)
+	}
+	return true
+}
+
+// find returns the leaf registered for exactly parts (param names included), or nil.
+func (n *node) find(parts []string, height int) *node {
+	if height == len(parts) {
+		if n.pattern == "" {
+			return nil
+		}
+		return n
+	}
+	part := parts[height]
+	seg, err := parseSegment(part)
+	if err != nil {
+		return nil
+	}
+	child, err := n.matchChildForInsert(part, seg)
+	if err != nil || child == nil || child.part != part {
+		return nil
+	}
+	return child.find(parts, height+1)
+}
+
+func (n *node) removeChild(child *node) {
+	switch {
+	case n.paramChild == child:
+		n.paramChild = nil
+	case n.wildChild == child:
+		n.wildChild = nil
+	default:
+		n.constrained = removeNode(n.constrained, child)
+		n.mixed = removeNode(n.mixed, child)
+		if n.staticChildren != nil && n.staticChildren.get(child.part) == child {
+			n.staticChildren.del(child.part)
+			if n.staticChildren.len() == 0 {
+				n.staticChildren = nil
+			}
+		}
+	}
+}
+
+func removeNode(nodes []*node, target *node) []*node {
+	for i, n := range nodes {
+		if n == target {
+			return append(nodes[:i], nodes[i+1:]...)
+		}
+	}
+	return nodes
+}
+
+func (n *node) isEmpty() bool {
+	return n.pattern == "" && n.staticChildren.len() == 0 && len(n.mixed) == 0 &&
+		len(n.constrained) == 0 && n.paramChild == nil && n.wildChild == nil
 }
 
-// search recursively matches a route (Static > Param > Wild).
+// anyLeaf reports whether fn returns true for any leaf in the subtree.
+func (n *node) anyLeaf(fn func(*node) bool) bool {
+	if n == nil {
+		return false
+	}
+	if n.pattern != "" && fn(n) {
+		return true
+	}
+	found := false
+	n.staticChildren.rangeFn(func(_ string, child *node) bool {
+		found = child.anyLeaf(fn)
+		return !found
+	})
+	if found {
+		return true
+	}
+	for _, child := range n.mixed {
+		if child.anyLeaf(fn) {
+			return true
+		}
+	}
+	for _, child := range n.constrained {
+		if child.anyLeaf(fn) {
+			return true
+		}
+	}
+	return n.paramChild.anyLeaf(fn) || n.wildChild.anyLeaf(fn)
+}
+
+// search recursively matches a route (Static > Mixed > Constrained Param > Param > Wild).
 // [Algorithmic Detail]:
 // The search function uses recursion but relies on the `segs` struct to avoid string slicing.
 // At each level `height`, we look at `segs.parts[height]`.
 //
 // 1. Check Static Children: O(1) in map or O(N) in small slice.
-// 2. Check Param Child: matches anything except empty (unless wildcard involved).
-// 3. Check Wildcard Child: matches EVERYTHING remaining.
+// 2. Check Mixed Children: literals must match, embedded params are sliced in place.
+// 3. Check Constrained Params: the segment value must satisfy the constraint.
+// 4. Check Param Child: matches anything except empty (unless wildcard involved).
+// 5. Check Wildcard Child: matches EVERYTHING remaining.
 //
 // Backtracking is implicitly handled by the order of checks. If Static fails, we try Param.
 func (n *node) search(segs *pathSegments, height int, params *Params) *node {
@@ -255,7 +416,7 @@ func (n *node) search(segs *pathSegments, height int, params *Params) *node {
 			if start < len(segs.path) && segs.path[start] == '/' {
 				start++
 			}
-			params.Add(n.part[1:], segs.path[start:])
+			params.Add(n.name, segs.path[start:])
 		}
 		return n
 	}
@@ -271,19 +432,50 @@ func (n *node) search(segs *pathSegments, height int, params *Params) *node {
 		}
 	}
 
-	// 2) Param
+	// 2) Mixed segments (literals on the match path, values from the original path)
+	for _, child := range n.mixed {
+		snapshot := 0
+		if params != nil {
+			snapshot = len(params.Keys)
+		}
+		if child.template.match(part, segs.value(height), params) {
+			if res := child.search(segs, height+1, params); res != nil {
+				return res
+			}
+		}
+		if params != nil {
+			params.Keys = params.Keys[:snapshot]
+			params.Values = params.Values[:snapshot]
+		}
+	}
+
+	// 3) Constrained params (validated against the original, non-lowercased value)
+	for _, child := range n.constrained {
+		value := segs.value(height)
+		if !child.constraint.match(value) {
+			continue
+		}
+		snapshot := 0
+		if params != nil {
+			snapshot = len(params.Keys)
+			params.Add(child.name, value)
+		}
+		if res := child.search(segs, height+1, params); res != nil {
+			return res
+		}
+		if params != nil {
+			params.Keys = params.Keys[:snapshot]
+			params.Values = params.Values[:snapshot]
+		}
+	}
+
+	// 4) Param
 	if child := n.paramChild; child != nil {
 		snapshot := 0
 		if params != nil {
 			snapshot = len(params.Keys)
 			// child.part looks like ":id"
-			start := segs.indices[height]
-			end := start + len(parts[height])
-			value := part
-			if start >= 0 && end <= len(segs.path) {
-				value = segs.path[start:end]
-			}
-			params.Add(child.part[1:], value)
+			params.Add(child.name, segs.value(height))
 		}
 
 		if res := child.search(segs, height+1, params); res != nil {
@@ -297,7 +489,7 @@ func (n *node) search(segs *pathSegments, height int, params *Params) *node {
 		}
 	}
 
-	// 3) Wildcard (consume from current segment; pass height)
+	// 5) Wildcard (consume from current segment; pass height)
 	if child := n.wildChild; child != nil {
 		if res := child.search(segs, height, params); res != nil {
 			return res
@@ -307,22 +499,74 @@ func (n *node) search(segs *pathSegments, height int, params *Params) *node {
 	return nil
 }
 
+// slashMatches reports whether the leaf's trailing slash agrees with path.
+// Segments ignore trailing slashes, so under StrictParamSlash a leaf must also match the
+// slash form exactly; the alternate form is then redirected like static routes are.
+func slashMatches(pattern, part, path string) bool {
+	if len(part) > 0 && part[0] == '*' {
+		return true
+	}
+	return (len(pattern) > 1 && pattern[len(pattern)-1] == '/') == (len(path) > 1 && path[len(path)-1] == '/')
+}
+
+// value returns segment i sliced from the original path (params keep their casing).
+func (segs *pathSegments) value(i int) string {
+	start := segs.indices[i]
+	end := start + len(segs.parts[i])
+	if start >= 0 && end <= len(segs.path) {
+		return segs.path[start:end]
+	}
+	return segs.parts[i]
+}
+
 // matchChildForInsert reuses a child by type.
-func (n *node) matchChildForInsert(part string) *node {
+// Constrained params are reused only when the constraint expression is identical,
+// mixed segments only when their literal structure is identical.
+func (n *node) matchChildForInsert(part string, seg segment) (*node, error) {
 	if part == "" {
-		return nil
+		return nil, nil
 	}
-	switch part[0] {
-	case ':':
-		return n.paramChild
-	case '*':
-		return n.wildChild
+	switch seg.kind {
+	case segmentParam:
+		if expr := seg.tokens[0].expr; expr != "" {
+			for _, child := range n.constrained {
+				if child.constraint.expr == expr {
+					return child, nil
+				}
+			}
+			return nil, nil
+		}
+		return n.paramChild, nil
+	case segmentMixed:
+		tmpl, err := compileTemplate(seg)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range n.mixed {
+			if child.template.key == tmpl.key {
+				return child, nil
+			}
+		}
+		return nil, nil
+	case segmentWildcard:
+		return n.wildChild, nil
 	default:
 		if n.staticChildren == nil {
-			return nil
+			return nil, nil
 		}
-		return n.staticChildren.get(part)
+		return n.staticChildren.get(part), nil
+	}
+}
+
+// addMixed inserts a mixed child keeping more literal bytes first (stable for ties).
+func (n *node) addMixed(child *node) {
+	i := len(n.mixed)
+	for i > 0 && n.mixed[i-1].template.literalLen < child.template.literalLen {
+		i--
 	}
+	n.mixed = append(n.mixed, nil)
+	copy(n.mixed[i+1:], n.mixed[i:])
+	n.mixed[i] = child
 }
 
 // Params holds route parameters.
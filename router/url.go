@@ -0,0 +1,152 @@
+package router
+
+import (
+	"fmt"
+	neturl "net/url"
+	"strings"
+)
+
+// URL builds the path of the named route, filling params from key/value pairs.
+// Param values are escaped with url.PathEscape; wildcard values keep their '/' separators.
+//
+// Example:
+//
+//	_ = r.HandleWith(http.MethodGet, "/users/:id", show, router.RouteOptions{Name: "user.show"})
+//	u, err := r.URL("user.show", "id", "42") // "/users/42"
+func (r *Router) URL(name string, pairs ...string) (string, error) {
+	r.mu.RLock()
+	info := r.names[name]
+	r.mu.RUnlock()
+	return buildURL(name, info, pairs)
+}
+
+// URL builds the path of the named route. See Router.URL.
+func (r *FrozenRouter) URL(name string, pairs ...string) (string, error) {
+	return buildURL(name, r.names[name], pairs)
+}
+
+func buildURL(name string, info *RouteInfo, pairs []string) (string, error) {
+	if info == nil {
+		return "", fmt.Errorf("unknown route name: %s", name)
+	}
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("odd number of param pairs for route %s", name)
+	}
+	lookup := func(key string) (string, bool) {
+		for i := 0; i < len(pairs); i += 2 {
+			if pairs[i] == key {
+				return pairs[i+1], true
+			}
+		}
+		return "", false
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		if !routeHasParam(info, pairs[i]) {
+			return "", fmt.Errorf("unknown param '%s' for route %s", pairs[i], name)
+		}
+	}
+
+	pattern, err := selectURLPattern(info.Pattern, lookup)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.Grow(len(pattern) + 16)
+	start := 0
+	for i := 0; i <= len(pattern); i++ {
+		if i < len(pattern) && pattern[i] != '/' {
+			continue
+		}
+		if part := pattern[start:i]; part != "" {
+			b.WriteByte('/')
+			if err := writeURLSegment(&b, name, part, lookup); err != nil {
+				return "", err
+			}
+		}
+		start = i + 1
+	}
+	if b.Len() == 0 || (len(pattern) > 1 && pattern[len(pattern)-1] == '/') {
+		b.WriteByte('/')
+	}
+	return b.String(), nil
+}
+
+// selectURLPattern resolves optional segments: the optional part is emitted
+// only when at least one of its params is provided.
+func selectURLPattern(pattern string, lookup func(string) (string, bool)) (string, error) {
+	variants, err := expandOptional(pattern)
+	if err != nil || len(variants) == 1 {
+		return pattern, err
+	}
+	base, full := variants[0], variants[1]
+	baseParams := make(map[string]struct{})
+	for _, part := range strings.Split(base, "/") {
+		if seg, err := parseSegment(part); err == nil {
+			for _, name := range seg.params() {
+				baseParams[name] = struct{}{}
+			}
+		}
+	}
+	for _, part := range strings.Split(full, "/") {
+		seg, err := parseSegment(part)
+		if err != nil {
+			continue
+		}
+		for _, name := range seg.params() {
+			if _, ok := baseParams[name]; ok {
+				continue
+			}
+			if _, ok := lookup(name); ok {
+				return full, nil
+			}
+		}
+	}
+	return base, nil
+}
+
+func writeURLSegment(b *strings.Builder, name, part string, lookup func(string) (string, bool)) error {
+	if !isDynamicPart(part) {
+		b.WriteString(part)
+		return nil
+	}
+	seg, err := parseSegment(part)
+	if err != nil {
+		return err
+	}
+	if seg.kind == segmentWildcard {
+		value, _ := lookup(seg.paramName())
+		writeWildcardValue(b, value)
+		return nil
+	}
+	for _, tok := range seg.tokens {
+		if tok.name == "" {
+			b.WriteString(tok.literal)
+			continue
+		}
+		value, ok := lookup(tok.name)
+		if !ok || value == "" {
+			return fmt.Errorf("missing param '%s' for route %s", tok.name, name)
+		}
+		b.WriteString(neturl.PathEscape(value))
+	}
+	return nil
+}
+
+func writeWildcardValue(b *strings.Builder, value string) {
+	value = strings.TrimPrefix(value, "/")
+	for i, seg := range strings.Split(value, "/") {
+		if i > 0 {
+			b.WriteByte('/')
+		}
+		b.WriteString(neturl.PathEscape(seg))
+	}
+}
+
+func routeHasParam(info *RouteInfo, key string) bool {
+	for _, p := range info.Params {
+		if p == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// VersionOptions configures how versioned routes (see Router.Version) read the API
+// version of a request. Sources are tried in field order; the first one naming a
+// version decides, even if the route does not serve it.
+type VersionOptions struct {
+	// MediaType is a vendor media type prefix read from Accept: "application/vnd.acme"
+	// reads "v2" from "application/vnd.acme.v2+json". The highest q-value wins.
+	MediaType string
+	// Header is a request header holding the version (e.g. "API-Version").
+	Header string
+	// Query is a query parameter holding the version (e.g. "version").
+	Query string
+	// Default is served when the request names no version. Empty means a version
+	// is required.
+	Default string
+	// NotAcceptable handles requests whose version the route does not serve.
+	// Defaults to an empty 406 Not Acceptable response.
+	NotAcceptable HandleFunc
+}
+
+// Version creates a group registering routes for one API version. The same method and
+// pattern may be registered once per version; requests are dispatched by
+// Router.Versioning. Unversioned routes are unaffected.
+func (r *Router) Version(version string) *Group {
+	g := newGroup(r, "", "", nil)
+	g.version = version
+	return g
+}
+
+// Version returns a copy of the group registering routes for one API version.
+func (g *Group) Version(version string) *Group {
+	c := newGroup(g.router, g.host, g.prefix, g.middlewares)
+	c.version = version
+	return c
+}
+
+// addVersionLocked adds a version to the registered route at leaves, swapping the
+// dispatcher of every variant. Caller must hold r.mu.
+func (r *Router) addVersionLocked(table *routeTable, method string, variants []routeVariant, leaves []*node, handler HandleFunc, wrapRoute bool, opts RouteOptions) error {
+	info := leaves[0].route
+	set := leaves[0].versions
+	if set == nil {
+		return fmt.Errorf("duplicate route: %s %s (registered without version)", method, info.Pattern)
+	}
+	if opts.Name != "" || opts.Doc != nil {
+		return fmt.Errorf("name and doc must be set with the first version of route: %s", info.Pattern)
+	}
+	if wrapRoute {
+		handler = withRoute(info, handler)
+	}
+	set, err := set.with(opts.Version, handler)
+	if err != nil {
+		return fmt.Errorf("%v for route: %s", err, info.Pattern)
+	}
+	for i, v := range variants {
+		leaves[i].handler = set.serve
+		leaves[i].versions = set
+		if !v.hasParams {
+			table.static[method][v.matchPattern] = set.serve
+		}
+	}
+	r.publishLocked()
+	return nil
+}
+
+// versionSet dispatches one route by API version. It is immutable: adding a version
+// builds a new set, so frozen routers keep the versions they were built with.
+type versionSet struct {
+	opts     VersionOptions
+	versions []string
+	handlers []HandleFunc
+	vary     string
+}
+
+func newVersionSet(opts VersionOptions) *versionSet {
+	s := &versionSet{opts: opts}
+	var vary []string
+	if opts.MediaType != "" {
+		vary = append(vary, "Accept")
+	}
+	if opts.Header != "" {
+		vary = append(vary, http.CanonicalHeaderKey(opts.Header))
+	}
+	s.vary = strings.Join(vary, ", ")
+	return s
+}
+
+// with returns a copy of s that also serves version with handler.
+func (s *versionSet) with(version string, handler HandleFunc) (*versionSet, error) {
+	if !isVersionToken(version) {
+		return nil, fmt.Errorf("invalid version: %q", version)
+	}
+	for _, v := range s.versions {
+		if v == version {
+			return nil, fmt.Errorf("duplicate version: %s", version)
+		}
+	}
+	c := *s
+	c.versions = append(append([]string(nil), s.versions...), version)
+	c.handlers = append(append([]HandleFunc(nil), s.handlers...), handler)
+	return &c, nil
+}
+
+func (s *versionSet) serve(w http.ResponseWriter, req *http.Request) {
+	if s.vary != "" {
+		w.Header().Add("Vary", s.vary)
+	}
+	version := s.requested(req)
+	for i, v := range s.versions {
+		if v == version {
+			s.handlers[i](w, req)
+			return
+		}
+	}
+	if s.opts.NotAcceptable != nil {
+		s.opts.NotAcceptable(w, req)
+		return
+	}
+	w.WriteHeader(http.StatusNotAcceptable)
+}
+
+// requested returns the version named by req, or the default.
+func (s *versionSet) requested(req *http.Request) string {
+	if s.opts.MediaType != "" {
+		if v, ok := acceptVersion(req.Header.Values("Accept"), s.opts.MediaType); ok {
+			return v
+		}
+	}
+	if s.opts.Header != "" {
+		if v := strings.TrimSpace(req.Header.Get(s.opts.Header)); v != "" {
+			return v
+		}
+	}
+	if s.opts.Query != "" && req.URL != nil {
+		if v, ok := queryValue(req.URL.RawQuery, s.opts.Query); ok && v != "" {
+			return v
+		}
+	}
+	return s.opts.Default
+}
+
+// acceptVersion finds "prefix.VERSION[+suffix]" media ranges in Accept headers and
+// returns the version with the highest q-value (the first one on ties).
+func acceptVersion(accept []string, prefix string) (string, bool) {
+	best, bestQ := "", -1.0
+	for _, header := range accept {
+		for header != "" {
+			var mediaRange string
+			mediaRange, header, _ = strings.Cut(header, ",")
+			mediaType, params, _ := strings.Cut(mediaRange, ";")
+			mediaType = strings.TrimSpace(mediaType)
+			if len(mediaType) <= len(prefix)+1 || mediaType[len(prefix)] != '.' ||
+				!strings.EqualFold(mediaType[:len(prefix)], prefix) {
+				continue
+			}
+			version := mediaType[len(prefix)+1:]
+			if i := strings.IndexByte(version, '+'); i >= 0 {
+				version = version[:i]
+			}
+			if version == "" {
+				continue
+			}
+			if q := qValue(params); q > bestQ {
+				best, bestQ = version, q
+			}
+		}
+	}
+	return best, bestQ > 0
+}
+
+// qValue parses the q parameter of a media range ("q=0.5; charset=utf-8"); default 1.
+func qValue(params string) float64 {
+	for params != "" {
+		var param string
+		param, params, _ = strings.Cut(params, ";")
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || !strings.EqualFold(name, "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(value, 64)
+		if err != nil || q < 0 || q > 1 {
+			return 0
+		}
+		return q
+	}
+	return 1
+}
+
+// queryValue returns the first value of key in a raw query without building url.Values.
+func queryValue(rawQuery, key string) (string, bool) {
+	for rawQuery != "" {
+		var pair string
+		pair, rawQuery, _ = strings.Cut(rawQuery, "&")
+		k, v, _ := strings.Cut(pair, "=")
+		if k != key {
+			continue
+		}
+		if strings.ContainsAny(v, "%+") {
+			unescaped, err := url.QueryUnescape(v)
+			if err != nil {
+				return "", false
+			}
+			v = unescaped
+		}
+		return v, true
+	}
+	return "", false
+}
+
+// isVersionToken reports whether version can be named by every source: non-empty,
+// without separators or spaces.
+func isVersionToken(version string) bool {
+	if version == "" {
+		return false
+	}
+	for i := 0; i < len(version); i++ {
+		c := version[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(`+;,"=&/\`, c) >= 0 {
+			return false
+		}
+	}
+	return true
+}